@@ -4,10 +4,13 @@ import (
 	"fmt"
 	"github.com/0xAX/notificator"
 	"github.com/troykinsella/bacon/executor"
+	"github.com/troykinsella/bacon/reporter"
 	"github.com/troykinsella/bacon/util"
 	"github.com/troykinsella/bacon/watcher"
+	"io"
 	"os"
 	"strconv"
+	"sync"
 	"text/template"
 	"time"
 )
@@ -27,38 +30,102 @@ const (
 )
 
 type Bacon struct {
-	w *watcher.W
-	e *executor.E
+	w    *watcher.W
+	e    *executor.E
+	last *executor.Result
+
+	// reloads holds a watcher of the configuration, whose changes call
+	// reload
+	reloads   *watcher.W
+	reload    ReloadFunc
+	reloadErr error
+	reloading sync.Mutex
+	mu        *sync.Mutex
+
+	// runs counts the runs in progress by executor, and idle is signalled
+	// when one finishes, so that a reload can wait for the runs of the
+	// executor that it replaces
+	runs map[*executor.E]int
+	idle *sync.Cond
+
+	showOutput  bool
+	notify      bool
+	color       bool
+	interactive bool
+	statusTpl   *template.Template
+	statusChan  chan *status
+	n           *notificator.Notificator
+	reporters   []reporter.R
+
+	// reportErr holds the first failure of the reporters to publish the last
+	// status, which is shown along with it. It's only used by the status
+	// printer.
+	reportErr error
+}
 
-	showOutput bool
-	notify     bool
-	statusChan chan *status
-	n          *notificator.Notificator
+// ReloadFunc reloads the configuration, returning what replaces the running
+// parts of the Bacon. When it fails, it may still return a replacement for
+// the watcher of the configuration.
+type ReloadFunc func() (*Reload, error)
+
+// Reload holds the replacements for the running watcher and executor, which
+// are nil when the configuration that they use is unchanged, and for the
+// watcher of the configuration, which is nil when the configuration's files
+// are unchanged.
+type Reload struct {
+	W       *watcher.W
+	E       *executor.E
+	Reloads *watcher.W
 }
 
 type status struct {
 	t       time.Time
 	running bool
 	passing bool
+	changed string
+	result  *executor.Result
 }
 
+// NewBacon creates a Bacon that renders its status line with the given
+// template, which should come from parseStatusFormat. When statusTpl is nil,
+// the default format for the output mode is used.
+//
+// When interactive is false, such as when stdout is not a terminal, the
+// screen is never cleared and the status line is never repainted, so that
+// the output reads as an append-only log.
 func NewBacon(
 	w *watcher.W,
 	e *executor.E,
 	showOutput bool,
-	notify bool) *Bacon {
+	notify bool,
+	color bool,
+	interactive bool,
+	statusTpl *template.Template,
+	reporters []reporter.R) *Bacon {
+
+	if statusTpl == nil {
+		statusTpl = defaultStatusTemplate(showOutput || !interactive)
+	}
 
 	statusChan := make(chan *status)
 
+	mu := &sync.Mutex{}
 	b := &Bacon{
-		w: w,
-		e: e,
-
-		showOutput: showOutput,
-		notify:     notify,
+		w:    w,
+		e:    e,
+		mu:   mu,
+		runs: make(map[*executor.E]int),
+		idle: sync.NewCond(mu),
+
+		showOutput:  showOutput,
+		notify:      notify,
+		color:       color,
+		interactive: interactive,
+		statusTpl:   statusTpl,
 
 		statusChan: statusChan,
 		n:          newNotificator(),
+		reporters:  reporters,
 	}
 
 	go b.statusPrinter()
@@ -73,11 +140,20 @@ func (b *Bacon) statusPrinter() {
 	for {
 		select {
 		case s := <-b.statusChan:
+			// A nil status reprints the last one, such as when the reload
+			// error changes
+			if s == nil {
+				if lastStatus != nil {
+					b.printStatus(lastStatus, false)
+				}
+				continue
+			}
 			lastStatus = s
+			b.report(s)
 			b.printStatus(s, false)
 
 		case <-time.After(time.Second):
-			if !b.showOutput {
+			if b.repaints() && lastStatus != nil {
 				b.printStatus(lastStatus, true)
 			}
 		}
@@ -90,53 +166,239 @@ func newNotificator() *notificator.Notificator {
 	})
 }
 
+// UseReload makes Run reload its configuration when the watcher reports a
+// change. When the reload returns a new watcher and executor, they replace
+// the running ones once the new watcher has started and the runs of the
+// running executor have finished, continuing the run history. When it
+// fails, the running ones are kept, and the error is shown along with the
+// status.
+func (b *Bacon) UseReload(w *watcher.W, reload ReloadFunc) {
+	b.reloads = w
+	b.reload = reload
+}
+
 func (b *Bacon) Run() error {
-	return b.w.Run(func(f string) {
-		b.statusChan <- &status{
-			t:       time.Now(),
-			running: true,
+	if b.reloads != nil {
+		go func() {
+			// Run the current watcher of the configuration until it fails,
+			// or is stopped on reload
+			for {
+				if err := b.reloadWatcher().Run(b.reloadChanged); err != nil {
+					b.setReloadErr(err)
+					return
+				}
+			}
+		}()
+	}
+
+	// Run the current watcher until it fails, or is stopped on reload
+	for {
+		if err := b.watcher().Run(b.changed); err != nil {
+			return err
 		}
+	}
+}
+
+func (b *Bacon) changed(f string) {
+	b.mu.Lock()
+	e := b.e
+	b.runs[e]++
+	last := b.last
+	b.mu.Unlock()
+
+	b.statusChan <- &status{
+		t:       time.Now(),
+		running: true,
+		changed: f,
+		result:  last,
+	}
+
+	r := e.RunCommands(f, nil)
+
+	b.mu.Lock()
+	b.last = r
+	b.runs[e]--
+	if b.runs[e] == 0 {
+		delete(b.runs, e)
+	}
+	b.idle.Broadcast()
+	b.mu.Unlock()
+
+	b.statusChan <- &status{
+		t:       r.FinishedAt,
+		passing: r.Passing,
+		changed: f,
+		result:  r,
+	}
 
-		r := b.e.RunCommands(f, nil)
+	b.pushNotification(r)
+}
+
+func (b *Bacon) reloadChanged(f string) {
+	if f == "" {
+		return // the initial callback, before any change
+	}
 
-		b.statusChan <- &status{
-			t:       r.FinishedAt,
-			passing: r.Passing,
+	// Changes in quick succession reload one at a time
+	b.reloading.Lock()
+	defer b.reloading.Unlock()
+
+	r, err := b.reload()
+	if r != nil && r.Reloads != nil {
+		if err := r.Reloads.Start(b.reloadChanged); err != nil {
+			b.setReloadErr(err)
+			return
 		}
 
-		b.pushNotification(r)
-	})
+		b.mu.Lock()
+		old := b.reloads
+		b.reloads = r.Reloads
+		b.mu.Unlock()
+
+		old.Stop()
+	}
+	if err != nil {
+		b.setReloadErr(err)
+		return
+	}
+
+	if r.W != nil {
+		// Keep the running watcher unless the new one starts
+		if err := r.W.Start(b.changed); err != nil {
+			b.setReloadErr(err)
+			return
+		}
+
+		b.mu.Lock()
+		for b.runs[b.e] > 0 {
+			b.idle.Wait()
+		}
+		r.E.Inherit(b.e)
+		old := b.w
+		b.w = r.W
+		b.e = r.E
+		b.mu.Unlock()
+
+		old.Stop()
+	}
+
+	b.setReloadErr(nil)
+}
+
+func (b *Bacon) setReloadErr(err error) {
+	b.mu.Lock()
+	changed := err != nil || b.reloadErr != nil
+	b.reloadErr = err
+	b.mu.Unlock()
+
+	if changed {
+		b.statusChan <- nil
+	}
+}
+
+func (b *Bacon) watcher() *watcher.W {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.w
+}
+
+func (b *Bacon) reloadWatcher() *watcher.W {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.reloads
+}
+
+func (b *Bacon) executor() *executor.E {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.e
+}
+
+// repaints answers whether the status line is redrawn in place, rather than
+// printed once per status change.
+func (b *Bacon) repaints() bool {
+	return !b.showOutput && b.interactive
 }
 
 func (b *Bacon) cls() {
-	if !b.showOutput {
+	if b.repaints() {
 		util.Cls()
 	}
 }
 
-func (b *Bacon) printStatus(s *status, repaint bool) {
+// parseStatusFormat parses a user-supplied status line template, and
+// validates it by rendering it against a sample set of status variables so
+// that unknown variables are reported at startup rather than on every print.
+func parseStatusFormat(format string) (*template.Template, error) {
+	tpl, err := template.New("status").
+		Option("missingkey=error").
+		Parse(format + "\n")
+	if err != nil {
+		return nil, fmt.Errorf("invalid status format: %s", err)
+	}
 
+	sample := (&Bacon{}).statusVars(&status{
+		t:       time.Now(),
+		passing: true,
+		result:  &executor.Result{},
+	})
+	if err := tpl.Execute(io.Discard, sample); err != nil {
+		return nil, fmt.Errorf("invalid status format: %s", err)
+	}
+
+	return tpl, nil
+}
+
+func defaultStatusTemplate(appendOnly bool) *template.Template {
 	statusFmt := outputStatusFormat
-	if !b.showOutput {
+	if !appendOnly {
 		statusFmt = noOutputStatusFormat
 	}
 
-	tpl, err := template.New("status").Parse(statusFmt + "\n")
+	tpl, err := parseStatusFormat(statusFmt)
 	if err != nil {
 		panic(err)
 	}
+	return tpl
+}
 
+func (b *Bacon) printStatus(s *status, repaint bool) {
 	vars := b.statusVars(s)
 
 	if s.running || s.passing {
 		b.cls()
 	}
 
-	if !b.showOutput && repaint {
-		fmt.Print("\033[1A                                \033[32D")
+	if b.repaints() && repaint {
+		fmt.Print("\033[1A\033[2K\r")
+	} else {
+		if err := b.reloadError(); err != nil {
+			b.printError("Baconfile not reloaded", err)
+		}
+		if b.reportErr != nil {
+			b.printError("Status not reported", b.reportErr)
+		}
 	}
 
-	_ = tpl.Execute(os.Stdout, vars)
+	_ = b.statusTpl.Execute(os.Stdout, vars)
+}
+
+// printError prints an error above the status line.
+func (b *Bacon) printError(msg string, err error) {
+	colorStart, colorEnd := "", ""
+	if b.color {
+		colorStart, colorEnd = "\033[31m", "\033[0m"
+	}
+	fmt.Printf("%s%s %s: %s%s\n", colorStart, symbolFailed, msg, err.Error(), colorEnd)
+}
+
+func (b *Bacon) reloadError() error {
+	if b.mu == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.reloadErr
 }
 
 func (b *Bacon) statusVars(s *status) map[string]string {
@@ -146,6 +408,7 @@ func (b *Bacon) statusVars(s *status) map[string]string {
 	var status string
 	var statusSymbol string
 	var colorStart string
+	var colorEnd string
 	var timeStamp string
 
 	if s.running {
@@ -168,14 +431,46 @@ func (b *Bacon) statusVars(s *status) map[string]string {
 		timeStamp = s.t.Format("15:04:05")
 	}
 
+	if b.color {
+		colorEnd = "\033[0m"
+	} else {
+		colorStart = ""
+	}
+
+	// The sample of parseStatusFormat has no watcher or executor
+	var target string
+	var watches int
+	if b.mu != nil {
+		target = b.executor().Target()
+		watches = b.watcher().Watches()
+	}
+
+	var duration string
+	var runCount int
+	var passStreak int
+	var failedCommand string
+	if r := s.result; r != nil {
+		duration = round(r.Duration, time.Millisecond).String()
+		runCount = r.RunCount
+		passStreak = r.PassStreak
+		failedCommand = r.FailedCommand
+	}
+
 	return map[string]string{
-		"showOutput":   strconv.FormatBool(b.showOutput),
-		"status":       status,
-		"statusSymbol": statusSymbol,
-		"colorStart":   colorStart,
-		"colorEnd":     "\033[0m",
-		"timeStamp":    timeStamp,
-		"timeSince":    round(now.Sub(s.t), time.Second).String(),
+		"showOutput":    strconv.FormatBool(b.showOutput),
+		"target":        target,
+		"status":        status,
+		"statusSymbol":  statusSymbol,
+		"colorStart":    colorStart,
+		"colorEnd":      colorEnd,
+		"timeStamp":     timeStamp,
+		"timeSince":     round(now.Sub(s.t), time.Second).String(),
+		"duration":      duration,
+		"changed":       s.changed,
+		"runCount":      strconv.Itoa(runCount),
+		"passStreak":    strconv.Itoa(passStreak),
+		"failedCommand": failedCommand,
+		"watches":       strconv.Itoa(watches),
 	}
 }
 
@@ -198,6 +493,25 @@ func round(d, r time.Duration) time.Duration {
 	return d
 }
 
+func (b *Bacon) report(s *status) {
+	state := reporter.Running
+	if !s.running {
+		if s.passing {
+			state = reporter.Passed
+		} else {
+			state = reporter.Failed
+		}
+	}
+
+	target := b.executor().Target()
+	b.reportErr = nil
+	for _, r := range b.reporters {
+		if err := r.Report(target, state); err != nil && b.reportErr == nil {
+			b.reportErr = err
+		}
+	}
+}
+
 func (b *Bacon) pushNotification(r *executor.Result) {
 	if !b.notify {
 		return
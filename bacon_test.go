@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"github.com/troykinsella/bacon/executor"
+	"github.com/troykinsella/bacon/expander"
+	"github.com/troykinsella/bacon/reporter"
+	"github.com/troykinsella/bacon/watcher"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBacon_Run_Reload(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", "Baconfile"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	log := filepath.Join(dir, "log")
+
+	newPair := func(glob string, name string) (*watcher.W, *executor.E) {
+		w, err := watcher.New(expander.New(dir, []string{glob}, []string{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		e := executor.New("t", executor.Commands([]string{"echo " + name + " >> " + log}), nil, nil, "", dir, false)
+		return w, e
+	}
+
+	w, e := newPair("a.txt", "a")
+	b := NewBacon(w, e, false, false, false, false, nil, nil)
+
+	config, err := watcher.New(expander.New(dir, []string{"Baconfile"}, []string{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloads := make(chan *Reload, 1)
+	b.UseReload(config, func() (*Reload, error) {
+		return <-reloads, nil
+	})
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- b.Run()
+	}()
+
+	expectLog := func(step string, exp string) {
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			out, _ := os.ReadFile(log)
+			if strings.Join(strings.Fields(string(out)), " ") == exp {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("%s: unexpected log:\nexpected=%s,\nactual=%s\n", step, exp, out)
+			}
+			select {
+			case err := <-errs:
+				t.Fatalf("%s: unexpected end of run: %v", step, err)
+			case <-time.After(50 * time.Millisecond):
+			}
+		}
+	}
+	touch := func(name string) {
+		// Give the watchers time to settle before changing the file
+		time.Sleep(200 * time.Millisecond)
+		// Append in one write, as truncating reports a change of its own
+		f, err := os.OpenFile(filepath.Join(dir, name), os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if _, err := f.WriteString(time.Now().String() + "\n"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expectLog("initial run", "a")
+
+	// A reload whose watcher matches nothing keeps the running pair
+	broken, _ := newPair("nope/*", "broken")
+	reloads <- &Reload{W: broken, E: executor.New("t", nil, nil, nil, "", dir, false)}
+	touch("Baconfile")
+	deadline := time.Now().Add(5 * time.Second)
+	for b.reloadError() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("expected reload error")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	touch("a.txt")
+	expectLog("after failed reload", "a a")
+
+	// A reload with a working watcher replaces the pair, and continues the
+	// run history
+	w, e = newPair("b.txt", "b")
+	reloads <- &Reload{W: w, E: e}
+	touch("Baconfile")
+	expectLog("after reload", "a a b")
+	if err := b.reloadError(); err != nil {
+		t.Errorf("unexpected reload error: %s", err.Error())
+	}
+
+	touch("b.txt")
+	expectLog("after change", "a a b b")
+
+	// The result is recorded after the commands finish
+	deadline = time.Now().Add(5 * time.Second)
+	for {
+		b.mu.Lock()
+		runCount := b.last.RunCount
+		b.mu.Unlock()
+		if runCount == 4 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("unexpected run count:\nexpected=4,\nactual=%d\n", runCount)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+type reporterFunc func(target string, state string) error
+
+func (f reporterFunc) Report(target string, state string) error {
+	return f(target, state)
+}
+
+func TestBacon_report(t *testing.T) {
+	failing := errors.New("failing")
+	var reported []string
+	b := NewBacon(nil, executor.New("t", nil, nil, nil, "", "", false), false, false, false, false, nil, []reporter.R{
+		reporterFunc(func(target string, state string) error {
+			if state == reporter.Failed {
+				return errors.New("first")
+			}
+			return nil
+		}),
+		reporterFunc(func(target string, state string) error {
+			reported = append(reported, target+" "+state)
+			return failing
+		}),
+	})
+
+	var tests = []struct {
+		s   *status
+		exp string
+	}{
+		{&status{running: true}, "failing"},
+		{&status{passing: false}, "first"},
+	}
+
+	for i, test := range tests {
+		b.report(test.s)
+		if b.reportErr == nil || b.reportErr.Error() != test.exp {
+			t.Errorf("%d. unexpected result:\nexpected=%s,\nactual=%v\n", i, test.exp, b.reportErr)
+		}
+	}
+	if strings.Join(reported, ",") != "t running,t failed" {
+		t.Errorf("unexpected reports: %v", reported)
+	}
+
+	b.reporters = b.reporters[:1]
+	b.report(&status{passing: true})
+	if b.reportErr != nil {
+		t.Errorf("unexpected error: %s", b.reportErr.Error())
+	}
+}
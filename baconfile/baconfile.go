@@ -1,30 +1,239 @@
 package baconfile
 
 import (
+	"errors"
 	"fmt"
-	"gopkg.in/yaml.v2"
+	"github.com/troykinsella/bacon/expander"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
 )
 
-var Version = "1.0"
+// Version is the current version of the Baconfile schema.
+var Version = "2"
+
+// The desc and schema tags of the Baconfile's types describe its JSON Schema,
+// and the schema tags' constraints are checked by Validate. See schema.go.
 
 type B struct {
-	Version string             `yaml:"version"`
-	Targets map[string]*Target `yaml:"target"`
+	Version     string             `yaml:"version" desc:"The version of the Baconfile schema"`
+	Include     []string           `yaml:"include,omitempty" desc:"Paths or glob patterns of other Baconfiles to include, relative to this one"`
+	Nested      bool               `yaml:"nested,omitempty" desc:"Add the targets of Baconfiles in subdirectories, named subdir:target"`
+	IgnoreFiles bool               `yaml:"ignore_files,omitempty" desc:"Exclude files ignored by .gitignore, .ignore, and .baconignore files"`
+	Vars        map[string]string  `yaml:"vars,omitempty" desc:"Variables to interpolate into targets as ${name}" schema:"keyPattern=^[A-Za-z_][A-Za-z0-9_]*$"`
+	Env         map[string]string  `yaml:"env,omitempty" desc:"Environment variables for the commands of all targets" schema:"keyPattern=^[^=\\s]+$"`
+	EnvFile     string             `yaml:"env_file,omitempty" desc:"The path to a .env file of environment variables for the commands of all targets"`
+	Targets     map[string]*Target `yaml:"targets" desc:"Targets, by name" schema:"required,minItems=1"`
+
+	// dir is the directory of the loaded Baconfile, on which relative paths
+	// are rooted
+	dir string
+
+	// files are the paths of the Baconfiles read to load this one
+	files []string
 }
 
 type Target struct {
-	Watch   []string `yaml:"watch"`
-	Exclude []string `yaml:"exclude,omitempty"`
-	Dir     string   `yaml:"dir,omitempty"`
-	Command []string `yaml:"command"`
-	Pass    []string `yaml:"pass,omitempty"`
-	Fail    []string `yaml:"fail,omitempty"`
-	Shell   string   `yaml:"shell,omitempty"`
+	Extends  string `yaml:"extends,omitempty" desc:"The name of a target from which to inherit fields"`
+	Abstract bool   `yaml:"abstract,omitempty" desc:"The target can only be extended, not run"`
+
+	Description string `yaml:"description,omitempty" desc:"A description of the target, for listings"`
+
+	Watch   []string          `yaml:"watch" desc:"Glob patterns of files to watch"`
+	Exclude []string          `yaml:"exclude,omitempty" desc:"Glob patterns of files to exclude from those watched"`
+	Dir     string            `yaml:"dir,omitempty" desc:"The directory on which globs are rooted, and in which commands run"`
+	Command []*Command        `yaml:"command" desc:"Commands to run when files change"`
+	Pass    []*Command        `yaml:"pass,omitempty" desc:"Commands to run when the commands pass"`
+	Fail    []*Command        `yaml:"fail,omitempty" desc:"Commands to run when the commands fail"`
+	Shell   string            `yaml:"shell,omitempty" desc:"The shell with which to run commands"`
+	Rules   []*Rule           `yaml:"rules,omitempty" desc:"Commands to run when particular files change"`
+	Params  []*Param          `yaml:"params,omitempty" desc:"Named parameters, given as --name value when running the target"`
+	Env     map[string]string `yaml:"env,omitempty" desc:"Environment variables for the target's commands" schema:"keyPattern=^[^=\\s]+$"`
+	EnvFile string            `yaml:"env_file,omitempty" desc:"The path to a .env file of environment variables for the target's commands"`
+
+	IgnoreFiles    *bool  `yaml:"ignore_files,omitempty" desc:"Exclude files ignored by .gitignore, .ignore, and .baconignore files"`
+	FollowSymlinks *bool  `yaml:"follow_symlinks,omitempty" desc:"Follow symlinks when matching and watching files"`
+	IgnoreCase     *bool  `yaml:"ignore_case,omitempty" desc:"Match globs case-insensitively"`
+	Poll           string `yaml:"poll,omitempty" desc:"Poll for file changes: true, or a polling interval such as 500ms" schema:"type=boolean|string"`
+	ContentHash    *bool  `yaml:"content_hash,omitempty" desc:"Ignore changes that leave file content as it was"`
+	GoPackages     string `yaml:"go_packages,omitempty" desc:"Pass the Go packages affected by a change to commands" schema:"enum=changed|dependents"`
+	StatusFormat   string `yaml:"status_format,omitempty" desc:"A Go template for the command status line"`
+
+	// file is the loaded Baconfile that defines the target, which differs
+	// from the Baconfile that contains it for nested targets
+	file *B
+
+	// dirRoot and envFileRoot are the directories on which the dir and
+	// env_file are rooted, when they're defined by an included Baconfile
+	dirRoot     string
+	envFileRoot string
+}
+
+// Rule routes changes to the files selected by its globs to its commands,
+// which run after the target's commands.
+type Rule struct {
+	Watch   []string   `yaml:"watch" desc:"Glob patterns of the files that the rule applies to" schema:"minItems=1"`
+	Exclude []string   `yaml:"exclude,omitempty" desc:"Glob patterns of files to exclude from those that the rule applies to"`
+	Command []*Command `yaml:"command" desc:"Commands to run when a file that the rule applies to changes" schema:"minItems=1"`
+}
+
+// Command is a shell command, given either as a string, or as a map with
+// "run" and "env" fields.
+type Command struct {
+	Run string            `yaml:"run" desc:"The command" schema:"required"`
+	Env map[string]string `yaml:"env,omitempty" desc:"Environment variables for the command" schema:"keyPattern=^[^=\\s]+$"`
+}
+
+// Commands creates commands without environment variables of their own.
+func Commands(runs ...string) []*Command {
+	var result []*Command
+	for _, r := range runs {
+		result = append(result, &Command{Run: r})
+	}
+	return result
+}
+
+func (c *Command) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if err := unmarshal(&c.Run); err == nil {
+		return nil
+	}
+	type plain Command
+	return unmarshal((*plain)(c))
+}
+
+func (c *Command) MarshalYAML() (interface{}, error) {
+	if len(c.Env) == 0 {
+		return c.Run, nil
+	}
+	type plain Command
+	return (*plain)(c), nil
+}
+
+// AllWatch returns the target's watch globs, followed by those of its rules,
+// since a file that a rule selects must be watched.
+func (t *Target) AllWatch() []string {
+	result := t.Watch[:len(t.Watch):len(t.Watch)]
+	for _, r := range t.Rules {
+		result = append(result, r.Watch...)
+	}
+	return result
+}
+
+// FollowsSymlinks answers whether the target follows symlinks.
+func (t *Target) FollowsSymlinks() bool {
+	return t.FollowSymlinks != nil && *t.FollowSymlinks
 }
 
+// IgnoresCase answers whether the target matches globs case-insensitively.
+func (t *Target) IgnoresCase() bool {
+	return t.IgnoreCase != nil && *t.IgnoreCase
+}
+
+// HashesContent answers whether the target ignores changes that leave file
+// content as it was.
+func (t *Target) HashesContent() bool {
+	return t.ContentHash != nil && *t.ContentHash
+}
+
+// PollInterval interprets the poll field, which is either a boolean or a
+// polling interval duration, such as "500ms". A zero interval means that
+// polling is enabled with the default interval.
+func (t *Target) PollInterval() (bool, time.Duration, error) {
+	switch t.Poll {
+	case "", "false":
+		return false, 0, nil
+	case "true":
+		return true, 0, nil
+	}
+
+	d, err := time.ParseDuration(t.Poll)
+	if err != nil || d <= 0 {
+		return false, 0, fmt.Errorf("invalid poll interval: %s", t.Poll)
+	}
+	return true, d, nil
+}
+
+// Equal answers whether the targets have the same configuration, regardless
+// of the Baconfiles that define them.
+func (t *Target) Equal(other *Target) bool {
+	a, b := *t, *other
+	a.file, b.file = nil, nil
+	return reflect.DeepEqual(a, b)
+}
+
+// UsesIgnoreFiles answers whether the target excludes files ignored by
+// .gitignore and similar files. A target inherits the Baconfile's setting
+// unless it overrides it.
+func (b *B) UsesIgnoreFiles(t *Target) bool {
+	if t.IgnoreFiles != nil {
+		return *t.IgnoreFiles
+	}
+	return b.owner(t).IgnoreFiles
+}
+
+// Dir returns the target's directory, rooted on the directory of the
+// Baconfile that defines it.
+func (b *B) Dir(t *Target) string {
+	return b.rooted(t, t.dirRoot, t.Dir)
+}
+
+// EnvFilePath returns the path to the target's env file, rooted on the
+// directory of the Baconfile that defines it.
+func (b *B) EnvFilePath(t *Target) string {
+	return b.rooted(t, t.envFileRoot, t.EnvFile)
+}
+
+// rooted roots the target's path on the directory, if any, or else on that of
+// the target's owner.
+func (b *B) rooted(t *Target, dir string, p string) string {
+	if dir == "" {
+		return b.owner(t).path(p)
+	}
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(dir, p)
+}
+
+// Files returns the absolute paths of the Baconfiles that were read to load
+// the Baconfile: its own, followed by those that it includes, and those that
+// are nested in it.
+func (b *B) Files() []string {
+	return b.files
+}
+
+// owner returns the Baconfile that defines the target.
+func (b *B) owner(t *Target) *B {
+	if t.file != nil {
+		return t.file
+	}
+	return b
+}
+
+// path roots the path on the Baconfile's directory.
+func (b *B) path(p string) string {
+	if b.dir == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(b.dir, p)
+}
+
+// Unmarshal decodes a YAML Baconfile, along with the Baconfiles that it
+// includes, relative to the working directory.
 func Unmarshal(bytes []byte) (*B, error) {
-	var b B
-	err := yaml.Unmarshal(bytes, &b)
+	return UnmarshalAs(bytes, YAML)
+}
+
+// UnmarshalAs decodes a Baconfile in the format, along with the Baconfiles
+// that it includes, relative to the working directory.
+func UnmarshalAs(bytes []byte, format Format) (*B, error) {
+	return unmarshal(bytes, format, ".", nil)
+}
+
+func unmarshal(bytes []byte, format Format, dir string, stack []string) (*B, error) {
+	b, err := parseExtended(bytes, format, dir, stack)
 	if err != nil {
 		return nil, err
 	}
@@ -33,43 +242,132 @@ func Unmarshal(bytes []byte) (*B, error) {
 		return nil, err
 	}
 
-	return &b, nil
+	return b, nil
+}
+
+// parseExtended parses the Baconfile, and resolves the targets that extend
+// others, without validating it.
+func parseExtended(bytes []byte, format Format, dir string, stack []string) (*B, error) {
+	b, err := parse(bytes, format, dir, stack)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := b.resolveExtends(); err != nil {
+		return nil, err
+	}
+
+	return b, nil
 }
 
 func errMalformed(msg string) error {
 	return fmt.Errorf("malformed Baconfile: %s", msg)
 }
 
+// Validate checks the Baconfile against its schema, and the targets for
+// problems that the schema can't express, such as undefined variables and
+// invalid globs. Errors name the YAML path of the problem, and the error
+// lists every problem found.
 func (b *B) Validate() error {
-	if len(b.Targets) == 0 {
-		return errMalformed("must supply at least one target")
+	errs := b.validate()
+	if len(errs) == 0 {
+		return nil
 	}
 
-	for tName, t := range b.Targets {
-		if len(t.Watch) == 0 {
-			return errMalformed(fmt.Sprintf("target '%s' must supply at least one 'watch' entry", tName))
-		}
-		if len(t.Command) == 0 && len(t.Pass) == 0 && len(t.Fail) == 0 {
-			return errMalformed(fmt.Sprintf("target '%s' must supply at least one 'command', 'pass', or 'fail' command", tName))
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return errMalformed(strings.Join(msgs, "; "))
+}
+
+// validate returns every problem that Validate finds.
+func (b *B) validate() []error {
+	var errs []error
+	for _, err := range checkSchema(reflect.ValueOf(b), "") {
+		errs = append(errs, errors.New(b.rootPath(err.Error())))
+	}
+
+	var names []string
+	for name := range b.Targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	runnable := false
+	for _, name := range names {
+		t := b.Targets[name]
+		// Abstract targets are only checked as part of the targets that
+		// extend them
+		if t.Abstract {
+			continue
 		}
+		runnable = true
+
+		errs = append(errs, b.checkTarget(t, joinPath(b.rootPath("targets"), name))...)
+	}
+	if !runnable && len(b.Targets) > 0 {
+		errs = append(errs, fmt.Errorf("%s: must have at least 1 target that isn't abstract", b.rootPath("targets")))
 	}
 
-	return nil
+	return errs
 }
 
-func (b *B) Marshal() ([]byte, error) {
-	if err := b.Validate(); err != nil {
-		return nil, err
+func (b *B) checkTarget(t *Target, path string) []error {
+	errs := b.checkParams(t, path)
+
+	// Check globs as they are after interpolation, whatever the environment
+	t, err := b.interpolateWith(t, nil, nil, nil, anyEnv)
+	if err != nil {
+		return append(errs, fmt.Errorf("%s: %s", path, err.Error()))
 	}
 
-	if b.Version == "" {
-		b.Version = Version
+	if len(t.Watch) == 0 && len(t.Rules) == 0 {
+		errs = append(errs, fmt.Errorf("%s.watch: is required without 'rules'", path))
+	}
+	if len(t.Command) == 0 && len(t.Pass) == 0 && len(t.Fail) == 0 && len(t.Rules) == 0 {
+		errs = append(errs, fmt.Errorf("%s.command: is required without 'pass', 'fail', or 'rules'", path))
+	}
+	errs = append(errs, checkGlobs(t.Watch, t.Exclude, path)...)
+	for i, r := range t.Rules {
+		errs = append(errs, checkGlobs(r.Watch, r.Exclude, fmt.Sprintf("%s.rules[%d]", path, i))...)
 	}
+	if _, _, err := t.PollInterval(); err != nil {
+		errs = append(errs, fmt.Errorf("%s.poll: %s", path, err.Error()))
+	}
+	return errs
+}
 
-	out, err := yaml.Marshal(b)
-	if err != nil {
-		return nil, err
+func checkGlobs(watch []string, exclude []string, path string) []error {
+	var errs []error
+	for field, globs := range [][]string{watch, exclude} {
+		for i, g := range globs {
+			if err := expander.CheckGlob(g); err != nil {
+				errs = append(errs, fmt.Errorf("%s.%s[%d]: %s", path, []string{"watch", "exclude"}[field], i, err.Error()))
+			}
+		}
+	}
+	return errs
+}
+
+// rootPath names the YAML path as it appears in the Baconfile, in which
+// targets are under "target" in the legacy schema.
+func (b *B) rootPath(path string) string {
+	if b.Version == Version || !strings.HasPrefix(path, "targets") {
+		return path
 	}
+	return "target" + strings.TrimPrefix(path, "targets")
+}
 
-	return out, nil
+// Marshal encodes the Baconfile in YAML, at the current version.
+func (b *B) Marshal() ([]byte, error) {
+	return b.MarshalAs(YAML)
+}
+
+// MarshalAs encodes the Baconfile in the format, at the current version.
+func (b *B) MarshalAs(format Format) ([]byte, error) {
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+	return b.encode(format)
 }
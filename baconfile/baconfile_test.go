@@ -2,6 +2,7 @@ package baconfile_test
 
 import (
 	"github.com/troykinsella/bacon/baconfile"
+	"path/filepath"
 	"reflect"
 	"testing"
 )
@@ -15,12 +16,12 @@ func TestUnmarshal(t *testing.T) {
 		{
 			"",
 			nil,
-			"malformed Baconfile: must supply at least one target",
+			"malformed Baconfile: target: must have at least 1 entry",
 		},
 		{
 			`--- { target: {} }`,
 			nil,
-			"malformed Baconfile: must supply at least one target",
+			"malformed Baconfile: target: must have at least 1 entry",
 		},
 		{
 			`--- { target: { foo: { watch: [bar], command: [echo] } } }`,
@@ -28,12 +29,165 @@ func TestUnmarshal(t *testing.T) {
 				Targets: map[string]*baconfile.Target{
 					"foo": {
 						Watch:   []string{"bar"},
-						Command: []string{"echo"},
+						Command: baconfile.Commands("echo"),
 					},
 				},
 			},
 			"",
 		},
+		{
+			`--- { version: "2", ignore_files: true, targets: { foo: { watch: [bar], command: [echo], ignore_files: false } } }`,
+			&baconfile.B{
+				Version:     baconfile.Version,
+				IgnoreFiles: true,
+				Targets: map[string]*baconfile.Target{
+					"foo": {
+						Watch:       []string{"bar"},
+						Command:     baconfile.Commands("echo"),
+						IgnoreFiles: new(bool),
+					},
+				},
+			},
+			"",
+		},
+		{
+			`--- { version: "2", targets: { foo: { watch: [bar], command: [echo], poll: 2s } } }`,
+			&baconfile.B{
+				Version: baconfile.Version,
+				Targets: map[string]*baconfile.Target{
+					"foo": {
+						Watch:   []string{"bar"},
+						Command: baconfile.Commands("echo"),
+						Poll:    "2s",
+					},
+				},
+			},
+			"",
+		},
+		{
+			`--- { version: "2", targets: { foo: { watch: [bar], command: [echo], poll: often } } }`,
+			nil,
+			"malformed Baconfile: targets.foo.poll: invalid poll interval: often",
+		},
+		{
+			`--- { version: "2", targets: { foo: { watch: [type:cobol], command: [echo] } } }`,
+			nil,
+			"malformed Baconfile: targets.foo.watch[0]: unknown file type 'cobol' in glob 'type:cobol': expected one of go, js, python, rust, ts",
+		},
+		{
+			`--- { version: "2", targets: { foo: { rules: [ { watch: ["*.proto"], command: [make proto] } ] } } }`,
+			&baconfile.B{
+				Version: baconfile.Version,
+				Targets: map[string]*baconfile.Target{
+					"foo": {
+						Rules: []*baconfile.Rule{
+							{
+								Watch:   []string{"*.proto"},
+								Command: baconfile.Commands("make proto"),
+							},
+						},
+					},
+				},
+			},
+			"",
+		},
+		{
+			`--- { version: "2", targets: { foo: { rules: [ { watch: ["*.proto"] } ] } } }`,
+			nil,
+			"malformed Baconfile: targets.foo.rules[0].command: must have at least 1 entry",
+		},
+		{
+			`--- { version: "2", targets: { foo: { watch: ["${SRC}/**"], command: [echo] } } }`,
+			nil,
+			"malformed Baconfile: targets.foo: undefined variable: SRC",
+		},
+		{
+			`--- { target: { foo: { watch: [bar], command: ["echo ${HOME}"] } } }`,
+			&baconfile.B{
+				Targets: map[string]*baconfile.Target{
+					"foo": {
+						Watch:   []string{"bar"},
+						Command: baconfile.Commands("echo ${HOME}"),
+					},
+				},
+			},
+			"",
+		},
+		{
+			`--- { version: "2", vars: { 1x: a }, targets: { foo: { watch: [bar], command: [echo] } } }`,
+			nil,
+			"malformed Baconfile: vars.1x: the name must match the pattern ^[A-Za-z_][A-Za-z0-9_]*$",
+		},
+		{
+			`--- { version: "2", vars: { pkg: a }, targets: { foo: { watch: [bar], command: [echo], params: [ { name: pkg } ] } } }`,
+			nil,
+			"malformed Baconfile: targets.foo.params[0].name: shadows variable 'pkg'",
+		},
+		{
+			`--- { version: "2", env: { A: a }, targets: { foo: { watch: [bar], command: [echo, { run: env, env: { B: b } }], env: { C: c }, env_file: .env } } }`,
+			&baconfile.B{
+				Version: baconfile.Version,
+				Env:     map[string]string{"A": "a"},
+				Targets: map[string]*baconfile.Target{
+					"foo": {
+						Watch: []string{"bar"},
+						Command: []*baconfile.Command{
+							{Run: "echo"},
+							{Run: "env", Env: map[string]string{"B": "b"}},
+						},
+						Env:     map[string]string{"C": "c"},
+						EnvFile: ".env",
+					},
+				},
+			},
+			"",
+		},
+		{
+			`--- { version: "2", targets: { foo: { watch: [bar], command: [echo], env: { "A=B": c } } } }`,
+			nil,
+			"malformed Baconfile: targets.foo.env[\"A=B\"]: the name must match the pattern ^[^=\\s]+$",
+		},
+		{
+			`--- { version: "2", targets: { foo: { watch: [bar], command: [{ env: { A: a } }] } } }`,
+			nil,
+			"malformed Baconfile: targets.foo.command[0].run: is required",
+		},
+		{
+			`--- { version: "2", targets: { foo: { watch: [bar], command: ["echo ${pkg}"], params: [ { name: pkg } ] } } }`,
+			&baconfile.B{
+				Version: baconfile.Version,
+				Targets: map[string]*baconfile.Target{
+					"foo": {
+						Watch:   []string{"bar"},
+						Command: baconfile.Commands("echo ${pkg}"),
+						Params:  []*baconfile.Param{{Name: "pkg"}},
+					},
+				},
+			},
+			"",
+		},
+		{
+			`--- { target: { foo: { watch: [bar], command: [echo], later: true } }, other: true }`,
+			&baconfile.B{
+				Targets: map[string]*baconfile.Target{
+					"foo": {
+						Watch:   []string{"bar"},
+						Command: baconfile.Commands("echo"),
+					},
+				},
+			},
+			"",
+		},
+		{
+			`--- { target: { foo: { watch: [bar], command: [echo], poll: 2s } } }`,
+			nil,
+			"malformed Baconfile: target.foo.poll: requires version 2: run 'bacon migrate'",
+		},
+		{
+			`--- { vars: { a: b }, target: { foo: { watch: [bar], command: [echo] } } }`,
+			nil,
+			"malformed Baconfile: vars: requires version 2: run 'bacon migrate'",
+		},
 	}
 
 	for i, test := range tests {
@@ -53,3 +207,92 @@ func TestUnmarshal(t *testing.T) {
 		}
 	}
 }
+
+func TestTarget_Equal(t *testing.T) {
+	dir := writeBaconfiles(t, map[string]string{
+		"Baconfile":     "version: \"2\"\ntargets:\n  a: { watch: [x], command: [ls] }\n",
+		"sub/Baconfile": "version: \"2\"\ntargets:\n  a: { watch: [x], command: [ls] }\n  b: { watch: [x], command: [pwd] }\n",
+	})
+	root, err := baconfile.Load(filepath.Join(dir, "Baconfile"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	sub, err := baconfile.Load(filepath.Join(dir, "sub", "Baconfile"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	var tests = []struct {
+		a   *baconfile.Target
+		b   *baconfile.Target
+		exp bool
+	}{
+		{root.Targets["a"], sub.Targets["a"], true},
+		{root.Targets["a"], sub.Targets["b"], false},
+	}
+
+	for i, test := range tests {
+		r := test.a.Equal(test.b)
+		if r != test.exp {
+			t.Errorf("%d. unexpected result:\nexpected=%t,\nactual=%t\n", i, test.exp, r)
+		}
+	}
+}
+
+func TestB_UsesIgnoreFiles(t *testing.T) {
+	yes := true
+	no := false
+
+	var tests = []struct {
+		root   bool
+		target *bool
+		exp    bool
+	}{
+		{false, nil, false},
+		{true, nil, true},
+		{true, &no, false},
+		{false, &yes, true},
+	}
+
+	for i, test := range tests {
+		b := &baconfile.B{IgnoreFiles: test.root}
+		r := b.UsesIgnoreFiles(&baconfile.Target{IgnoreFiles: test.target})
+		if r != test.exp {
+			t.Errorf("%d. unexpected result:\nexpected=%t,\nactual=%t\n", i, test.exp, r)
+		}
+	}
+}
+
+func TestB_Marshal(t *testing.T) {
+	b := &baconfile.B{
+		Targets: map[string]*baconfile.Target{
+			"foo": {
+				Watch: []string{"bar"},
+				Command: []*baconfile.Command{
+					{Run: "echo"},
+					{Run: "env", Env: map[string]string{"B": "b"}},
+				},
+			},
+		},
+	}
+
+	out, err := b.Marshal()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	exp := `version: "2"
+targets:
+  foo:
+    watch:
+    - bar
+    command:
+    - echo
+    - run: env
+      env:
+        B: b
+`
+	if string(out) != exp {
+		t.Errorf("unexpected result:\nexpected=%s,\nactual=%s\n", exp, out)
+	}
+}
@@ -0,0 +1,118 @@
+package baconfile
+
+import (
+	"fmt"
+	"github.com/troykinsella/bacon/executor"
+	"github.com/troykinsella/bacon/expander"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var positionalArg = regexp.MustCompile(`\$[0-9]+`)
+
+// Check performs the checks of the Baconfile's targets that depend on the
+// environment, which Validate doesn't: that directories and env files exist,
+// that watch globs match files, and that shells are found in the PATH. It
+// returns every problem found, each prefixed with its YAML path. Globs that
+// depend on positional arguments or required parameters aren't checked.
+func (b *B) Check() []error {
+	var names []string
+	for name, t := range b.Targets {
+		if !t.Abstract {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var errs []error
+	if b.EnvFile != "" {
+		if _, err := ReadEnvFile(b.path(b.EnvFile)); err != nil {
+			errs = append(errs, fmt.Errorf("env_file: %s", err.Error()))
+		}
+	}
+	for _, name := range names {
+		errs = append(errs, b.checkEnvironment(b.Targets[name], joinPath(b.rootPath("targets"), name))...)
+	}
+	return errs
+}
+
+func (b *B) checkEnvironment(raw *Target, path string) []error {
+	var errs []error
+	fail := func(path string, format string, a ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", path, fmt.Sprintf(format, a...)))
+	}
+
+	t, err := b.Interpolate(raw, nil, nil, nil)
+	if err != nil {
+		fail(path, "%s", err.Error())
+		return errs
+	}
+
+	dir := b.Dir(t)
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		fail(path+".dir", "directory not found: %s", dir)
+		dir = ""
+	}
+
+	if t.EnvFile != "" {
+		if _, err := ReadEnvFile(b.EnvFilePath(t)); err != nil {
+			fail(path+".env_file", "%s", err.Error())
+		}
+	}
+
+	shell := t.Shell
+	if shell == "" {
+		shell = executor.DefaultShell
+	}
+	if _, err := exec.LookPath(shell); err != nil {
+		fail(path+".shell", "not found in PATH: %s", shell)
+	}
+
+	checkMatches := func(path string, rawWatch []string, watch []string, exclude []string) {
+		if dir == "" {
+			return
+		}
+		for i, g := range watch {
+			if strings.HasPrefix(g, "!") || t.DependsOnArgs(rawWatch[i]) {
+				continue
+			}
+			exp := expander.New(dir, []string{g}, exclude)
+			if t.FollowsSymlinks() {
+				exp.FollowSymlinks()
+			}
+			if t.IgnoresCase() {
+				exp.IgnoreCase()
+			}
+			files, err := exp.List()
+			if err != nil {
+				fail(fmt.Sprintf("%s[%d]", path, i), "%s", err.Error())
+			} else if len(files) == 0 {
+				fail(fmt.Sprintf("%s[%d]", path, i), "matches no files: %s", g)
+			}
+		}
+	}
+	checkMatches(path+".watch", raw.Watch, t.Watch, t.Exclude)
+	for i, r := range t.Rules {
+		checkMatches(fmt.Sprintf("%s.rules[%d].watch", path, i), raw.Rules[i].Watch, r.Watch, r.Exclude)
+	}
+
+	return errs
+}
+
+// DependsOnArgs answers whether the uninterpolated string refers to
+// positional arguments, or to parameters of the target without defaults, so
+// that its value isn't known until the target is run.
+func (t *Target) DependsOnArgs(s string) bool {
+	if positionalArg.MatchString(s) {
+		return true
+	}
+	for _, p := range t.Params {
+		if p.Default == nil && strings.Contains(s, "${"+p.Name) {
+			return true
+		}
+	}
+	return false
+}
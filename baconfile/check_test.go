@@ -0,0 +1,100 @@
+package baconfile_test
+
+import (
+	"github.com/troykinsella/bacon/baconfile"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestB_Check(t *testing.T) {
+	dir := writeBaconfiles(t, map[string]string{
+		"src/a.go": "package a",
+		"Baconfile": `---
+version: "2"
+targets:
+  ok:
+    watch: [ "src/**/*.go", "!src/b.go", "$1/*.go", "${pkg}/*.go" ]
+    params: [ { name: pkg } ]
+    command: [ "go test" ]
+  missing:
+    watch: [ "docs/*.md" ]
+    env_file: .env
+    shell: no-such-shell
+    rules: [ { watch: [ "*.txt" ], command: [ ls ] } ]
+  nodir:
+    dir: nope
+    watch: [ "*.go" ]
+    command: [ ls ]
+  base:
+    abstract: true
+    dir: nope
+`,
+	})
+
+	bf, err := baconfile.Load(filepath.Join(dir, "Baconfile"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	var result []string
+	for _, err := range bf.Check() {
+		result = append(result, err.Error())
+	}
+
+	exp := []string{
+		"targets.missing.env_file: open " + filepath.Join(dir, ".env") + ": no such file or directory",
+		"targets.missing.shell: not found in PATH: no-such-shell",
+		"targets.missing.watch[0]: matches no files: docs/*.md",
+		"targets.missing.rules[0].watch[0]: matches no files: *.txt",
+		"targets.nodir.dir: directory not found: " + filepath.Join(dir, "nope"),
+	}
+	if !reflect.DeepEqual(exp, result) {
+		t.Errorf("unexpected result:\nexpected=%#v,\nactual=%#v\n", exp, result)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	dir := writeBaconfiles(t, map[string]string{
+		"Baconfile": `---
+version: "2"
+nested: true
+vars: { 1x: a }
+targets:
+  nocmd:
+    watch: [ "*.go" ]
+  undefined:
+    watch: [ "${SRC}/*.go" ]
+    command: [ ls ]
+  nodir:
+    dir: nope
+    watch: [ "*.go" ]
+    exclude: [ "[" ]
+    command: [ ls ]
+`,
+		"sub/Baconfile": `--- { version: "2", targets: { test: { watch: [x] } } }`,
+	})
+
+	_, errs, err := baconfile.Inspect(filepath.Join(dir, "Baconfile"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	var result []string
+	for _, err := range errs {
+		result = append(result, err.Error())
+	}
+
+	exp := []string{
+		"vars.1x: the name must match the pattern ^[A-Za-z_][A-Za-z0-9_]*$",
+		"targets.nocmd.command: is required without 'pass', 'fail', or 'rules'",
+		"targets.nodir.exclude[0]: invalid glob '[': syntax error in pattern",
+		"targets.undefined: undefined variable: SRC",
+		filepath.Join(dir, "sub", "Baconfile") + ": malformed Baconfile: targets.test.command: is required without 'pass', 'fail', or 'rules'",
+		"targets.nocmd.watch[0]: matches no files: *.go",
+		"targets.nodir.dir: directory not found: " + filepath.Join(dir, "nope"),
+	}
+	if !reflect.DeepEqual(exp, result) {
+		t.Errorf("unexpected result:\nexpected=%#v,\nactual=%#v\n", exp, result)
+	}
+}
@@ -0,0 +1,109 @@
+package baconfile
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Environ returns the environment variables for the target's commands, in
+// KEY=VALUE form: those of the Baconfile's env_file and env, followed by
+// those of the target's env_file and env, so that later entries take
+// precedence.
+func (b *B) Environ(t *Target) ([]string, error) {
+	owner := b.owner(t)
+
+	var result []string
+	for _, level := range []struct {
+		file string
+		path string
+		env  map[string]string
+	}{
+		{owner.EnvFile, owner.path(owner.EnvFile), owner.Env},
+		{t.EnvFile, b.EnvFilePath(t), t.Env},
+	} {
+		if level.file != "" {
+			env, err := ReadEnvFile(level.path)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, environ(env)...)
+		}
+		result = append(result, environ(level.env)...)
+	}
+	return result, nil
+}
+
+// Environ returns the command's own environment variables, in KEY=VALUE
+// form.
+func (c *Command) Environ() []string {
+	return environ(c.Env)
+}
+
+// ReadEnvFile reads a .env file, in which each line is a KEY=VALUE pair,
+// optionally prefixed with "export". Values may be quoted, and lines starting
+// with "#" are comments.
+func ReadEnvFile(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+
+		i := strings.Index(line, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, n)
+		}
+		key := strings.TrimSpace(line[:i])
+		value, err := unquote(strings.TrimSpace(line[i+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", path, n, err.Error())
+		}
+		result[key] = value
+	}
+	return result, scanner.Err()
+}
+
+func unquote(value string) (string, error) {
+	if len(value) < 2 {
+		return value, nil
+	}
+	switch value[0] {
+	case '"':
+		return strconv.Unquote(value)
+	case '\'':
+		if value[len(value)-1] != '\'' {
+			return "", errors.New("unterminated quoted value")
+		}
+		return value[1 : len(value)-1], nil
+	}
+	return value, nil
+}
+
+// environ formats the variables as KEY=VALUE pairs, sorted by key.
+func environ(env map[string]string) []string {
+	var keys []string
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	result := make([]string, len(keys))
+	for i, k := range keys {
+		result[i] = k + "=" + env[k]
+	}
+	return result
+}
@@ -0,0 +1,80 @@
+package baconfile_test
+
+import (
+	"github.com/troykinsella/bacon/baconfile"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadEnvFile(t *testing.T) {
+	var tests = []struct {
+		content string
+		exp     map[string]string
+		err     string
+	}{
+		{
+			"# comment\n\nA=a\nexport B = b \nC=\"c\\td\"\nD='$e'\nE=x=y\n",
+			map[string]string{"A": "a", "B": "b", "C": "c\td", "D": "$e", "E": "x=y"},
+			"",
+		},
+		{
+			"A=a\nnope\n",
+			nil,
+			".env:2: expected KEY=VALUE",
+		},
+		{
+			"A='a\n",
+			nil,
+			".env:1: unterminated quoted value",
+		},
+	}
+
+	dir := t.TempDir()
+	for i, test := range tests {
+		path := filepath.Join(dir, ".env")
+		if err := os.WriteFile(path, []byte(test.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := baconfile.ReadEnvFile(path)
+		if test.err != "" {
+			exp := filepath.Join(dir, test.err)
+			if err == nil || err.Error() != exp {
+				t.Errorf("%d. unexpected error:\nexpected=%s,\nactual=%v\n", i, exp, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d. unexpected error: %s\n", i, err.Error())
+		} else if !reflect.DeepEqual(r, test.exp) {
+			t.Errorf("%d. unexpected result:\nexpected=%#v,\nactual=%#v\n", i, test.exp, r)
+		}
+	}
+}
+
+func TestB_Environ(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".env")
+	if err := os.WriteFile(path, []byte("A=file\nB=file\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	b := &baconfile.B{
+		Env: map[string]string{"B": "root", "C": "root"},
+	}
+	target := &baconfile.Target{
+		Env:     map[string]string{"C": "target"},
+		EnvFile: path,
+	}
+
+	r, err := b.Environ(target)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	exp := []string{"B=root", "C=root", "A=file", "B=file", "C=target"}
+	if !reflect.DeepEqual(r, exp) {
+		t.Errorf("unexpected result:\nexpected=%#v,\nactual=%#v\n", exp, r)
+	}
+}
@@ -0,0 +1,153 @@
+package baconfile
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Inherited is the list entry that stands for the entries of the extended
+// target, so that a list can add to them rather than replace them.
+const Inherited = "..."
+
+// resolveExtends replaces each target that extends another with the result of
+// merging it over the target that it extends.
+func (b *B) resolveExtends() error {
+	resolved := make(map[string]*Target)
+
+	var resolve func(name string, chain []string) (*Target, error)
+	resolve = func(name string, chain []string) (*Target, error) {
+		if t, ok := resolved[name]; ok {
+			return t, nil
+		}
+		for _, c := range chain {
+			if c == name {
+				return nil, errMalformed(fmt.Sprintf("target '%s' has a cyclic 'extends'", chain[0]))
+			}
+		}
+
+		t := b.Targets[name]
+		if t.Extends != "" {
+			if b.Targets[t.Extends] == nil {
+				return nil, errMalformed(fmt.Sprintf("target '%s' extends unknown target '%s'", name, t.Extends))
+			}
+			base, err := resolve(t.Extends, append(chain, name))
+			if err != nil {
+				return nil, err
+			}
+			t = mergeTarget(base, t)
+		}
+
+		resolved[name] = t
+		return t, nil
+	}
+
+	for name := range b.Targets {
+		if _, err := resolve(name, nil); err != nil {
+			return err
+		}
+	}
+	b.Targets = resolved
+	return nil
+}
+
+// mergeTarget returns a target with the fields of t, and those of base that t
+// doesn't set. Lists replace those of base, unless they contain Inherited,
+// which is replaced with the list of base. Maps and parameters are merged,
+// with t taking precedence. Abstract isn't inherited.
+func mergeTarget(base *Target, t *Target) *Target {
+	result := *t
+	rv := reflect.ValueOf(&result).Elem()
+	bv := reflect.ValueOf(base).Elem()
+
+	for i := 0; i < rv.NumField(); i++ {
+		if !rv.Type().Field(i).IsExported() {
+			continue
+		}
+		f := rv.Field(i)
+		bf := bv.Field(i)
+
+		switch f.Kind() {
+		case reflect.Slice:
+			f.Set(mergeList(bf, f))
+		case reflect.Map:
+			f.Set(mergeMap(bf, f))
+		default:
+			if f.IsZero() {
+				f.Set(bf)
+			}
+		}
+	}
+
+	// Inherited paths stay rooted where the extended target defines them
+	if t.Dir == "" && base.Dir != "" {
+		result.dirRoot = base.dirRoot
+	}
+	if t.EnvFile == "" && base.EnvFile != "" {
+		result.envFileRoot = base.envFileRoot
+	}
+
+	result.Params = mergeParams(base.Params, t.Params)
+	result.Extends = t.Extends
+	result.Abstract = t.Abstract
+	return &result
+}
+
+func mergeList(base reflect.Value, list reflect.Value) reflect.Value {
+	if list.IsNil() {
+		return base
+	}
+
+	result := reflect.MakeSlice(list.Type(), 0, list.Len()+base.Len())
+	for i := 0; i < list.Len(); i++ {
+		if isInherited(list.Index(i)) {
+			result = reflect.AppendSlice(result, base)
+		} else {
+			result = reflect.Append(result, list.Index(i))
+		}
+	}
+	return result
+}
+
+func isInherited(v reflect.Value) bool {
+	switch e := v.Interface().(type) {
+	case string:
+		return e == Inherited
+	case *Command:
+		return e.Run == Inherited && len(e.Env) == 0
+	}
+	return false
+}
+
+func mergeMap(base reflect.Value, m reflect.Value) reflect.Value {
+	if base.Len() == 0 {
+		return m
+	}
+
+	result := reflect.MakeMap(base.Type())
+	for _, src := range []reflect.Value{base, m} {
+		iter := src.MapRange()
+		for iter.Next() {
+			result.SetMapIndex(iter.Key(), iter.Value())
+		}
+	}
+	return result
+}
+
+func mergeParams(base []*Param, params []*Param) []*Param {
+	var result []*Param
+	for _, p := range base {
+		if findParam(params, p.Name) == nil {
+			result = append(result, p)
+		}
+	}
+	return append(result, params...)
+}
+
+func findParam(params []*Param, name string) *Param {
+	for _, p := range params {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package baconfile_test
+
+import (
+	"github.com/troykinsella/bacon/baconfile"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnmarshal_Extends(t *testing.T) {
+	b, err := baconfile.Unmarshal([]byte(`---
+version: "2"
+targets:
+  base:
+    abstract: true
+    watch: [ "**/*.go" ]
+    exclude: [ "vendor" ]
+    command: [ "go test ${pkg}" ]
+    env: { A: base, B: base }
+    params: [ { name: pkg, default: "./..." } ]
+  api:
+    extends: base
+    dir: api
+    exclude: [ "...", "testdata" ]
+    env: { B: api }
+  web:
+    extends: api
+    command: [ "...", "npm test" ]
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	def := "./..."
+	exp := &baconfile.Target{
+		Extends: "api",
+		Dir:     "api",
+		Watch:   []string{"**/*.go"},
+		Exclude: []string{"vendor", "testdata"},
+		Command: baconfile.Commands("go test ${pkg}", "npm test"),
+		Env:     map[string]string{"A": "base", "B": "api"},
+		Params:  []*baconfile.Param{{Name: "pkg", Default: &def}},
+	}
+	if !reflect.DeepEqual(b.Targets["web"], exp) {
+		t.Errorf("unexpected result:\nexpected=%#v,\nactual=%#v\n", exp, b.Targets["web"])
+	}
+	if !b.Targets["base"].Abstract || b.Targets["api"].Abstract {
+		t.Errorf("unexpected abstract targets")
+	}
+}
+
+func TestUnmarshal_ExtendsBools(t *testing.T) {
+	b, err := baconfile.Unmarshal([]byte(`---
+version: "2"
+targets:
+  base:
+    abstract: true
+    watch: [ "**/*.go" ]
+    command: [ "go test" ]
+    follow_symlinks: true
+    ignore_case: true
+    content_hash: true
+  off:
+    extends: base
+    follow_symlinks: false
+    ignore_case: false
+    content_hash: false
+  on:
+    extends: base
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	var tests = []struct {
+		name string
+		exp  bool
+	}{
+		{"off", false},
+		{"on", true},
+	}
+
+	for i, test := range tests {
+		target := b.Targets[test.name]
+		actual := []bool{target.FollowsSymlinks(), target.IgnoresCase(), target.HashesContent()}
+		exp := []bool{test.exp, test.exp, test.exp}
+		if !reflect.DeepEqual(actual, exp) {
+			t.Errorf("%d. unexpected result:\nexpected=%v,\nactual=%v\n", i, exp, actual)
+		}
+	}
+}
+
+func TestUnmarshal_ExtendsErrors(t *testing.T) {
+	var tests = []struct {
+		b   string
+		err string
+	}{
+		{
+			`--- { version: "2", targets: { foo: { extends: bar, watch: [x], command: [echo] } } }`,
+			"malformed Baconfile: target 'foo' extends unknown target 'bar'",
+		},
+		{
+			`--- { version: "2", targets: { foo: { extends: foo, watch: [x], command: [echo] } } }`,
+			"malformed Baconfile: target 'foo' has a cyclic 'extends'",
+		},
+		{
+			`--- { version: "2", targets: { foo: { abstract: true, watch: [x] } } }`,
+			"malformed Baconfile: targets: must have at least 1 target that isn't abstract",
+		},
+		{
+			`--- { version: "2", targets: { foo: { abstract: true, watch: [x] }, bar: { extends: foo } } }`,
+			"malformed Baconfile: targets.bar.command: is required without 'pass', 'fail', or 'rules'",
+		},
+	}
+
+	for i, test := range tests {
+		_, err := baconfile.Unmarshal([]byte(test.b))
+		if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+			t.Errorf("%d. unexpected error:\nexpected=%s,\nactual=%v\n", i, test.err, err)
+		}
+	}
+}
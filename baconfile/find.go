@@ -0,0 +1,143 @@
+package baconfile
+
+import (
+	"errors"
+	"fmt"
+	"github.com/troykinsella/bacon/expander"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// FileNames are the names of Baconfiles, in the order in which they're
+// searched for in a directory. The extension selects the Format.
+var FileNames = []string{
+	"Baconfile",
+	"Baconfile.yml",
+	"Baconfile.yaml",
+	"Baconfile.toml",
+	"Baconfile.json",
+	".Baconfile",
+	".Baconfile.yml",
+	".Baconfile.yaml",
+	".Baconfile.toml",
+	".Baconfile.json",
+}
+
+// NestedSeparator separates the directory of a nested Baconfile from the
+// names of its targets.
+const NestedSeparator = ":"
+
+// Find searches for a Baconfile in the directory, then in each of its
+// parents up to the root of the repository containing it, like git does. It
+// returns an empty path when none is found.
+func Find(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		path, err := FindIn(dir)
+		if err != nil || path != "" {
+			return path, err
+		}
+
+		if isRepoRoot(dir) {
+			return "", nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// FindIn returns the path of the Baconfile in the directory, if any, without
+// searching its parents.
+func FindIn(dir string) (string, error) {
+	for _, name := range FileNames {
+		path := filepath.Join(dir, name)
+		fi, err := os.Stat(path)
+		if err == nil && !fi.IsDir() {
+			return path, nil
+		}
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+func isRepoRoot(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+	return err == nil
+}
+
+// nestedExcludes are the globs of the directories in which nested
+// Baconfiles aren't searched for: hidden directories, and those of
+// dependencies.
+var nestedExcludes = []string{
+	"**/.*/**",
+	"**/node_modules/**",
+	"**/bower_components/**",
+	"**/vendor/**",
+}
+
+// loadNested adds the targets of the Baconfiles in the subdirectories of
+// the Baconfile's directory, named "subdir:target". Directories excluded by
+// nestedExcludes, or ignored by ignore files, are skipped. The errors of
+// all the nested Baconfiles that fail to load are returned together.
+func (b *B) loadNested() error {
+	var includes []string
+	for _, name := range FileNames {
+		includes = append(includes, "*/**/"+name)
+	}
+	exp := expander.New(b.dir, includes, nestedExcludes)
+	exp.UseIgnoreFiles()
+
+	found, err := exp.List()
+	if err != nil {
+		return err
+	}
+	dirs := make(map[string]bool)
+	for _, p := range found {
+		dirs[filepath.Dir(p)] = true
+	}
+	var sorted []string
+	for dir := range dirs {
+		sorted = append(sorted, dir)
+	}
+	sort.Strings(sorted)
+
+	var errs []error
+	for _, dir := range sorted {
+		bfPath, err := FindIn(dir)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if bfPath == "" {
+			continue
+		}
+
+		nested, err := load(bfPath, false)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s", bfPath, err.Error()))
+			continue
+		}
+
+		rel, err := filepath.Rel(b.dir, dir)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		b.files = append(b.files, nested.files...)
+		prefix := filepath.ToSlash(rel) + NestedSeparator
+		for name, t := range nested.Targets {
+			b.Targets[prefix+name] = t
+		}
+	}
+	return errors.Join(errs...)
+}
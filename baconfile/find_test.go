@@ -0,0 +1,138 @@
+package baconfile_test
+
+import (
+	"github.com/troykinsella/bacon/baconfile"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	dir := writeBaconfiles(t, map[string]string{
+		"Baconfile":                   "",
+		"repo/.git/HEAD":              "",
+		"repo/.Baconfile.yml":         "",
+		"repo/a/b/c/.keep":            "",
+		"repo/a/Baconfile.yaml":       "",
+		"repo/x/y/.keep":              "",
+		"norepo/Baconfile.yml":        "",
+		"norepo/x/.keep":              "",
+		"repo/sub/.git/HEAD":          "",
+		"repo/sub/nothing/here/.keep": "",
+	})
+
+	var tests = []struct {
+		start string
+		exp   string
+	}{
+		{"repo", "repo/.Baconfile.yml"},
+		{"repo/a/b/c", "repo/a/Baconfile.yaml"},
+		{"repo/x/y", "repo/.Baconfile.yml"},
+		{"norepo/x", "norepo/Baconfile.yml"},
+		{"repo/sub/nothing/here", ""},
+	}
+
+	for i, test := range tests {
+		r, err := baconfile.Find(filepath.Join(dir, test.start))
+		if err != nil {
+			t.Errorf("%d. unexpected error: %s\n", i, err.Error())
+			continue
+		}
+		exp := ""
+		if test.exp != "" {
+			exp = filepath.Join(dir, test.exp)
+		}
+		if r != exp {
+			t.Errorf("%d. unexpected result:\nexpected=%s,\nactual=%s\n", i, exp, r)
+		}
+	}
+}
+
+func TestLoad_Nested(t *testing.T) {
+	dir := writeBaconfiles(t, map[string]string{
+		"Baconfile": `--- { version: "2", nested: true, targets: { default: { watch: [x], command: [y] } } }`,
+		"svc/api/Baconfile": `---
+version: "2"
+vars: { v: api }
+env: { E: api }
+targets:
+  test: { dir: src, watch: [x], command: [ "echo ${v}" ] }
+`,
+		"svc/web/Baconfile.yml":    `--- { target: { test: { watch: [x], command: [y] } } }`,
+		".hidden/Baconfile":        `--- { target: { test: { watch: [x], command: [y] } } }`,
+		"node_modules/x/Baconfile": `--- { target: { test: { watch: [x], command: [y] } } }`,
+		"svc/vendor/Baconfile":     `--- { target: { test: { watch: [x], command: [y] } } }`,
+		"build/Baconfile":          `--- { target: { test: { watch: [x], command: [y] } } }`,
+		".gitignore":               "build/\n",
+	})
+
+	b, err := baconfile.Load(filepath.Join(dir, "Baconfile"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	var names []string
+	for name := range b.Targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "default,svc/api:test,svc/web:test" {
+		t.Errorf("unexpected targets: %v", names)
+	}
+
+	api := b.Targets["svc/api:test"]
+	if d := b.Dir(api); d != filepath.Join(dir, "svc/api/src") {
+		t.Errorf("unexpected dir: %s", d)
+	}
+	if d := b.Dir(b.Targets["default"]); d != dir {
+		t.Errorf("unexpected dir: %s", d)
+	}
+
+	it, err := b.Interpolate(api, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if it.Command[0].Run != "echo api" {
+		t.Errorf("unexpected command: %s", it.Command[0].Run)
+	}
+	env, err := b.Environ(api)
+	if err != nil || strings.Join(env, ",") != "E=api" {
+		t.Errorf("unexpected environment: %v, %v", env, err)
+	}
+
+	exp := []string{
+		filepath.Join(dir, "Baconfile"),
+		filepath.Join(dir, "svc/api/Baconfile"),
+		filepath.Join(dir, "svc/web/Baconfile.yml"),
+	}
+	if files := b.Files(); strings.Join(files, ",") != strings.Join(exp, ",") {
+		t.Errorf("unexpected files:\nexpected=%v,\nactual=%v\n", exp, files)
+	}
+}
+
+func TestLoad_NestedErrors(t *testing.T) {
+	dir := writeBaconfiles(t, map[string]string{
+		"Baconfile":       `--- { version: "2", nested: true, targets: { default: { watch: [x], command: [y] } } }`,
+		"a/Baconfile":     `--- { target: { test: { watch: [x] } } }`,
+		"b/Baconfile":     `--- { target: { test: { watch: [x], command: [y] } } }`,
+		"c/Baconfile.yml": `--- { version: "2", targets: { test: { bogus: true } } }`,
+	})
+
+	_, err := baconfile.Load(filepath.Join(dir, "Baconfile"))
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+
+	for _, exp := range []string{
+		filepath.Join(dir, "a", "Baconfile") + ": ",
+		filepath.Join(dir, "c", "Baconfile.yml") + ": ",
+	} {
+		if !strings.Contains(err.Error(), exp) {
+			t.Errorf("unexpected error:\nexpected=%s...,\nactual=%s\n", exp, err.Error())
+		}
+	}
+	if strings.Contains(err.Error(), filepath.Join(dir, "b")) {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+}
@@ -0,0 +1,174 @@
+package baconfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+	"path/filepath"
+	"strings"
+)
+
+// Format is the file format of a Baconfile.
+type Format string
+
+const (
+	YAML Format = "yaml"
+	TOML Format = "toml"
+	JSON Format = "json"
+)
+
+// Formats are the supported file formats, the first being the default.
+var Formats = []Format{YAML, TOML, JSON}
+
+// FormatOf returns the format of the Baconfile at the path, according to its
+// extension. A Baconfile without a .toml or .json extension is YAML.
+func FormatOf(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		return TOML
+	case ".json":
+		return JSON
+	}
+	return YAML
+}
+
+// ParseFormat returns the named format.
+func ParseFormat(name string) (Format, error) {
+	for _, f := range Formats {
+		if string(f) == strings.ToLower(name) {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported format: %s", name)
+}
+
+// Ext returns the file name extension of the format, which is empty for
+// YAML, since a Baconfile is YAML by default.
+func (f Format) Ext() string {
+	if f == YAML {
+		return ""
+	}
+	return "." + string(f)
+}
+
+// toYAML converts a TOML or JSON Baconfile to YAML, so that every format is
+// decoded and validated alike.
+func toYAML(in []byte, format Format) ([]byte, error) {
+	var v interface{}
+	switch format {
+	case TOML:
+		var m map[string]interface{}
+		if _, err := toml.Decode(string(in), &m); err != nil {
+			return nil, errMalformed(err.Error())
+		}
+		v = m
+	case JSON:
+		if err := json.Unmarshal(in, &v); err != nil {
+			return nil, errMalformed(err.Error())
+		}
+	default:
+		return in, nil
+	}
+	return yaml.Marshal(v)
+}
+
+// encode encodes the Baconfile in the format, at the current version. Fields
+// are in the order of the YAML encoding, except in TOML, which orders them
+// itself.
+func (b *B) encode(format Format) ([]byte, error) {
+	b.Version = Version
+	out, err := yaml.Marshal(b)
+	if err != nil || format == YAML {
+		return out, err
+	}
+	return fromYAML(out, format)
+}
+
+// fromYAML converts a YAML Baconfile to the format.
+func fromYAML(in []byte, format Format) ([]byte, error) {
+	var doc yaml.MapSlice
+	if err := yaml.Unmarshal(in, &doc); err != nil {
+		return nil, err
+	}
+
+	if format == JSON {
+		out, err := json.MarshalIndent(ordered(doc), "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(out, '\n'), nil
+	}
+
+	var out bytes.Buffer
+	enc := toml.NewEncoder(&out)
+	enc.Indent = ""
+	if err := enc.Encode(plain(doc)); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// orderedMap encodes a YAML map as a JSON object with its keys in order.
+type orderedMap yaml.MapSlice
+
+func (m orderedMap) MarshalJSON() ([]byte, error) {
+	var out bytes.Buffer
+	out.WriteByte('{')
+	for i, item := range m {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		key, err := json.Marshal(fmt.Sprint(item.Key))
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(ordered(item.Value))
+		if err != nil {
+			return nil, err
+		}
+		out.Write(key)
+		out.WriteByte(':')
+		out.Write(value)
+	}
+	out.WriteByte('}')
+	return out.Bytes(), nil
+}
+
+// ordered converts decoded YAML to values that JSON encodes in order.
+func ordered(v interface{}) interface{} {
+	switch v := v.(type) {
+	case yaml.MapSlice:
+		return orderedMap(v)
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, e := range v {
+			result[i] = ordered(e)
+		}
+		return result
+	}
+	return v
+}
+
+// plain converts decoded YAML to maps and slices that TOML can encode,
+// dropping null values, which TOML can't represent.
+func plain(v interface{}) interface{} {
+	switch v := v.(type) {
+	case yaml.MapSlice:
+		result := make(map[string]interface{})
+		for _, item := range v {
+			if item.Value != nil {
+				result[fmt.Sprint(item.Key)] = plain(item.Value)
+			}
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, e := range v {
+			result[i] = plain(e)
+		}
+		return result
+	}
+	return v
+}
@@ -0,0 +1,196 @@
+package baconfile_test
+
+import (
+	"github.com/troykinsella/bacon/baconfile"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFormatOf(t *testing.T) {
+	var tests = []struct {
+		path string
+		exp  baconfile.Format
+	}{
+		{"Baconfile", baconfile.YAML},
+		{"Baconfile.yml", baconfile.YAML},
+		{"Baconfile.toml", baconfile.TOML},
+		{"dir/.Baconfile.JSON", baconfile.JSON},
+	}
+
+	for i, test := range tests {
+		r := baconfile.FormatOf(test.path)
+		if r != test.exp {
+			t.Errorf("%d. unexpected result:\nexpected=%s,\nactual=%s\n", i, test.exp, r)
+		}
+	}
+}
+
+func TestUnmarshalAs(t *testing.T) {
+	exp := &baconfile.B{
+		Version: "2",
+		Targets: map[string]*baconfile.Target{
+			"test": {
+				Watch:   []string{"**/*.go"},
+				Command: []*baconfile.Command{{Run: "go test"}, {Run: "go vet", Env: map[string]string{"A": "b"}}},
+				Poll:    "true",
+			},
+		},
+	}
+
+	var tests = []struct {
+		b      string
+		format baconfile.Format
+		err    string
+	}{
+		{
+			`
+version = "2"
+
+[targets.test]
+watch = [ "**/*.go" ]
+command = [ "go test", { run = "go vet", env = { A = "b" } } ]
+poll = true
+`,
+			baconfile.TOML,
+			"",
+		},
+		{
+			`{
+	"version": "2",
+	"targets": {
+		"test": {
+			"watch": [ "**/*.go" ],
+			"command": [ "go test", { "run": "go vet", "env": { "A": "b" } } ],
+			"poll": true
+		}
+	}
+}`,
+			baconfile.JSON,
+			"",
+		},
+		{
+			"version = \"2\"\n[targets.test]\nwach = [ \"x\" ]\n",
+			baconfile.TOML,
+			"malformed Baconfile: targets.test: unknown field 'wach' in a target",
+		},
+		{
+			`{ "version": "2", "extra": 1, "targets": { "test": { "watch": [ "x" ], "command": [ { "run": "y", "evn": {} } ] } } }`,
+			baconfile.JSON,
+			"malformed Baconfile: unknown field 'extra' in the Baconfile root; targets.test.command[0]: unknown field 'evn' in a command",
+		},
+		{
+			`{ "version": "2", "targets": { "test": { "watch": [ "x" ] } } }`,
+			baconfile.JSON,
+			"malformed Baconfile: targets.test.command: is required without 'pass', 'fail', or 'rules'",
+		},
+	}
+
+	for i, test := range tests {
+		out, err := baconfile.UnmarshalAs([]byte(test.b), test.format)
+		if test.err == "" {
+			if err != nil {
+				t.Errorf("%d. unexpected error: %s\n", i, err.Error())
+			} else if !reflect.DeepEqual(exp, out) {
+				t.Errorf("%d. unexpected result:\nexpected=%#v,\nactual=%#v\n", i, exp, out)
+			}
+		} else {
+			if err == nil {
+				t.Errorf("%d. expected error:\nexpected=%s,\nactual=nil\n", i, test.err)
+			} else if test.err != err.Error() {
+				t.Errorf("%d. unexpected error:\nexpected=%s,\nactual=%s\n", i, test.err, err.Error())
+			}
+		}
+	}
+}
+
+func TestB_MarshalAs_RoundTrip(t *testing.T) {
+	def := "./..."
+	ignore := false
+	b := &baconfile.B{
+		Vars: map[string]string{"src": "src"},
+		Env:  map[string]string{"CGO_ENABLED": "0"},
+		Targets: map[string]*baconfile.Target{
+			"test": {
+				Watch:       []string{"${src}/**/*.go"},
+				Exclude:     []string{"vendor"},
+				Command:     []*baconfile.Command{{Run: "go test ${pkg}"}, {Run: "go vet", Env: map[string]string{"A": "b"}}},
+				Pass:        baconfile.Commands("echo ok"),
+				Params:      []*baconfile.Param{{Name: "pkg", Default: &def, Description: "The packages"}},
+				Rules:       []*baconfile.Rule{{Watch: []string{"*.proto"}, Command: baconfile.Commands("make proto")}},
+				IgnoreFiles: &ignore,
+				GoPackages:  "changed",
+			},
+		},
+	}
+
+	for _, format := range baconfile.Formats {
+		out, err := b.MarshalAs(format)
+		if err != nil {
+			t.Fatalf("%s. unexpected error: %s", format, err.Error())
+		}
+		r, err := baconfile.UnmarshalAs(out, format)
+		if err != nil {
+			t.Fatalf("%s. unexpected error: %s\n%s", format, err.Error(), out)
+		}
+		if !reflect.DeepEqual(b, r) {
+			t.Errorf("%s. unexpected result:\nexpected=%#v,\nactual=%#v\n", format, b, r)
+		}
+	}
+}
+
+func TestLoad_Formats(t *testing.T) {
+	dir := writeBaconfiles(t, map[string]string{
+		"Baconfile.toml": `
+version = "2"
+include = [ "shared.json" ]
+
+[targets.a]
+watch = [ "x" ]
+command = [ "a" ]
+`,
+		"shared.json": `{ "version": "2", "targets": { "b": { "watch": [ "y" ], "command": [ "b" ] } } }`,
+	})
+
+	path, err := baconfile.Find(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if exp := filepath.Join(dir, "Baconfile.toml"); path != exp {
+		t.Errorf("unexpected result:\nexpected=%s,\nactual=%s\n", exp, path)
+	}
+
+	b, err := baconfile.Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	for _, name := range []string{"a", "b"} {
+		if b.Targets[name] == nil {
+			t.Errorf("missing target: %s", name)
+		}
+	}
+}
+
+func TestMigrateAs(t *testing.T) {
+	out, err := baconfile.MigrateAs([]byte(`{ "target": { "a": { "watch": [ "x" ], "command": [ "a" ] } } }`), baconfile.JSON)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	exp := `{
+  "version": "2",
+  "targets": {
+    "a": {
+      "watch": [
+        "x"
+      ],
+      "command": [
+        "a"
+      ]
+    }
+  }
+}
+`
+	if string(out) != exp {
+		t.Errorf("unexpected result:\nexpected=%s,\nactual=%s\n", exp, out)
+	}
+}
@@ -0,0 +1,203 @@
+package baconfile
+
+import (
+	"fmt"
+	"github.com/bmatcuk/doublestar"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Load reads the Baconfile at the path, in the format of its extension,
+// along with the Baconfiles that it includes, relative to its directory.
+// When the Baconfile is nested, the targets of the Baconfiles in its
+// subdirectories are added.
+func Load(path string) (*B, error) {
+	return load(path, true)
+}
+
+// Inspect reads the Baconfile at the path like Load, but rather than failing
+// on the problems that Validate finds, returns every one of them, followed by
+// those of the nested Baconfiles, and those that Check finds. An error is
+// returned when the Baconfile can't be read at all.
+func Inspect(path string) (*B, []error, error) {
+	b, err := read(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	problems := b.validate()
+	if b.Nested {
+		if err := b.loadNested(); err != nil {
+			if joined, ok := err.(interface{ Unwrap() []error }); ok {
+				problems = append(problems, joined.Unwrap()...)
+			} else {
+				problems = append(problems, err)
+			}
+		}
+	}
+
+	// Check repeats some of the problems that Validate finds
+	seen := make(map[string]bool)
+	for _, err := range problems {
+		seen[err.Error()] = true
+	}
+	for _, err := range b.Check() {
+		if !seen[err.Error()] {
+			problems = append(problems, err)
+		}
+	}
+	return b, problems, nil
+}
+
+func load(path string, nested bool) (*B, error) {
+	b, err := read(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+
+	if nested && b.Nested {
+		if err := b.loadNested(); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// read reads the Baconfile at the path, along with the Baconfiles that it
+// includes, without validating it.
+func read(path string) (*B, error) {
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := parseExtended(bytes, FormatOf(abs), filepath.Dir(abs), []string{abs})
+	if err != nil {
+		return nil, err
+	}
+
+	b.dir = filepath.Dir(abs)
+	b.files = append([]string{abs}, b.files...)
+	for _, t := range b.Targets {
+		t.file = b
+	}
+	return b, nil
+}
+
+// parse decodes the Baconfile in the format, and merges in the Baconfiles
+// that it includes. The stack holds the paths of the including Baconfiles,
+// to detect cycles.
+func parse(bytes []byte, format Format, dir string, stack []string) (*B, error) {
+	b, err := decode(bytes, format)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, inc := range b.Include {
+		paths, err := includePaths(dir, inc)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range paths {
+			for _, s := range stack {
+				if s == p {
+					return nil, errMalformed(fmt.Sprintf("cyclic include of %s", p))
+				}
+			}
+
+			incBytes, err := os.ReadFile(p)
+			if err != nil {
+				return nil, err
+			}
+			inc, err := parse(incBytes, FormatOf(p), filepath.Dir(p), append(stack, p))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", p, err.Error())
+			}
+			inc.rootPaths(filepath.Dir(p))
+			b.merge(inc)
+			b.files = append(b.files, p)
+			b.files = append(b.files, inc.files...)
+		}
+	}
+
+	return b, nil
+}
+
+// includePaths returns the absolute paths of the files matched by the
+// include glob. A glob without magic must match a file.
+func includePaths(dir string, inc string) ([]string, error) {
+	if !filepath.IsAbs(inc) {
+		inc = filepath.Join(dir, inc)
+	}
+
+	inc, err := filepath.Abs(inc)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.ContainsAny(inc, "*?[{") {
+		if _, err := os.Stat(inc); err != nil {
+			return nil, err
+		}
+		return []string{inc}, nil
+	}
+
+	paths, err := doublestar.Glob(inc)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// rootPaths roots the dir and env_file of the included Baconfile's targets on
+// its directory, rather than on that of the including Baconfile.
+func (b *B) rootPaths(dir string) {
+	for _, t := range b.Targets {
+		if t.dirRoot == "" {
+			t.dirRoot = dir
+		}
+		if t.envFileRoot == "" {
+			t.envFileRoot = dir
+		}
+	}
+}
+
+// merge adds the targets, variables, and environment variables of the
+// included Baconfile that aren't already defined.
+func (b *B) merge(inc *B) {
+	if len(inc.Targets) > 0 && b.Targets == nil {
+		b.Targets = make(map[string]*Target)
+	}
+	for name, t := range inc.Targets {
+		if _, ok := b.Targets[name]; !ok {
+			b.Targets[name] = t
+		}
+	}
+
+	b.Vars = mergeMissing(b.Vars, inc.Vars)
+	b.Env = mergeMissing(b.Env, inc.Env)
+}
+
+func mergeMissing(m map[string]string, other map[string]string) map[string]string {
+	if len(other) > 0 && m == nil {
+		m = make(map[string]string)
+	}
+	for k, v := range other {
+		if _, ok := m[k]; !ok {
+			m[k] = v
+		}
+	}
+	return m
+}
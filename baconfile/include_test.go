@@ -0,0 +1,157 @@
+package baconfile_test
+
+import (
+	"github.com/troykinsella/bacon/baconfile"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func writeBaconfiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoad_Include(t *testing.T) {
+	dir := writeBaconfiles(t, map[string]string{
+		"Baconfile": `---
+include: [ "shared.yml", "teams/*/Baconfile" ]
+vars: { v: root }
+version: "2"
+targets:
+  default: { extends: base, watch: [x] }
+  a: { watch: [x], command: [root] }
+`,
+		"shared.yml": `---
+vars: { v: shared, w: shared }
+version: "2"
+targets:
+  base: { abstract: true, command: [ "echo ${v} ${w}" ] }
+`,
+		"teams/a/Baconfile": `--- { version: "2", targets: { a: { watch: [x], command: [team] } } }`,
+		"teams/b/Baconfile": `--- { version: "2", targets: { b: { watch: [x], command: [team] } } }`,
+	})
+
+	b, err := baconfile.Load(filepath.Join(dir, "Baconfile"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	var names []string
+	for name := range b.Targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "a,b,base,default" {
+		t.Errorf("unexpected targets: %v", names)
+	}
+	if b.Targets["a"].Command[0].Run != "root" {
+		t.Errorf("unexpected command: %s", b.Targets["a"].Command[0].Run)
+	}
+	if b.Vars["v"] != "root" || b.Vars["w"] != "shared" {
+		t.Errorf("unexpected vars: %v", b.Vars)
+	}
+	if b.Targets["default"].Command[0].Run != "echo ${v} ${w}" {
+		t.Errorf("unexpected default command: %s", b.Targets["default"].Command[0].Run)
+	}
+
+	exp := []string{
+		filepath.Join(dir, "Baconfile"),
+		filepath.Join(dir, "shared.yml"),
+		filepath.Join(dir, "teams/a/Baconfile"),
+		filepath.Join(dir, "teams/b/Baconfile"),
+	}
+	if files := b.Files(); strings.Join(files, ",") != strings.Join(exp, ",") {
+		t.Errorf("unexpected files:\nexpected=%v,\nactual=%v\n", exp, files)
+	}
+}
+
+func TestLoad_IncludePaths(t *testing.T) {
+	dir := writeBaconfiles(t, map[string]string{
+		"Baconfile": `---
+include: [ "shared/Baconfile" ]
+version: "2"
+targets:
+  root: { watch: [x], command: [root], dir: src, env_file: .env }
+  child: { extends: shared, env_file: .env }
+`,
+		".env": "A=root\n",
+		"shared/Baconfile": `---
+version: "2"
+targets:
+  shared: { watch: [x], command: [shared], dir: src, env_file: .env }
+  here: { watch: [x], command: [here] }
+`,
+		"shared/.env": "A=shared\n",
+	})
+
+	b, err := baconfile.Load(filepath.Join(dir, "Baconfile"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	var tests = []struct {
+		target string
+		expDir string
+		expEnv string
+	}{
+		{"root", filepath.Join(dir, "src"), "A=root"},
+		{"shared", filepath.Join(dir, "shared", "src"), "A=shared"},
+		{"here", filepath.Join(dir, "shared"), ""},
+		{"child", filepath.Join(dir, "shared", "src"), "A=root"},
+	}
+
+	for i, test := range tests {
+		target := b.Targets[test.target]
+		env, err := b.Environ(target)
+		if err != nil {
+			t.Errorf("%d. unexpected error: %s\n", i, err.Error())
+			continue
+		}
+		actualDir := b.Dir(target)
+		actualEnv := strings.Join(env, " ")
+		if actualDir != test.expDir || actualEnv != test.expEnv {
+			t.Errorf("%d. unexpected result:\nexpected=%s, %s,\nactual=%s, %s\n", i, test.expDir, test.expEnv, actualDir, actualEnv)
+		}
+	}
+}
+
+func TestLoad_IncludeErrors(t *testing.T) {
+	var tests = []struct {
+		files map[string]string
+		err   string
+	}{
+		{
+			map[string]string{
+				"Baconfile": `--- { version: "2", include: [ missing.yml ], targets: { a: { watch: [x], command: [y] } } }`,
+			},
+			"no such file or directory",
+		},
+		{
+			map[string]string{
+				"Baconfile": `--- { version: "2", include: [ other.yml ], targets: { a: { watch: [x], command: [y] } } }`,
+				"other.yml": `--- { version: "2", include: [ Baconfile ] }`,
+			},
+			"malformed Baconfile: cyclic include of",
+		},
+	}
+
+	for i, test := range tests {
+		dir := writeBaconfiles(t, test.files)
+		_, err := baconfile.Load(filepath.Join(dir, "Baconfile"))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%d. unexpected error:\nexpected=%s,\nactual=%v\n", i, test.err, err)
+		}
+	}
+}
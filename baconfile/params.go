@@ -0,0 +1,62 @@
+package baconfile
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Param declares a named target parameter, which is given on the command line
+// as "--name value", and referenced as "${name}". A parameter without a
+// default is required.
+type Param struct {
+	Name        string  `yaml:"name" desc:"The parameter name" schema:"required,pattern=^[A-Za-z_][A-Za-z0-9_]*$"`
+	Default     *string `yaml:"default,omitempty" desc:"The value when the parameter isn't given; without one, the parameter is required"`
+	Description string  `yaml:"description,omitempty" desc:"A description of the parameter, for help output"`
+}
+
+// Param returns the target's parameter with the given name, or nil.
+func (t *Target) Param(name string) *Param {
+	return findParam(t.Params, name)
+}
+
+// ParamValues checks the given parameter values against the target's
+// declared parameters, and returns them merged with the defaults.
+func (t *Target) ParamValues(given map[string]string) (map[string]string, error) {
+	var names []string
+	for name := range given {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if t.Param(name) == nil {
+			return nil, fmt.Errorf("unknown parameter: --%s", name)
+		}
+	}
+
+	result := make(map[string]string)
+	for _, p := range t.Params {
+		if v, ok := given[p.Name]; ok {
+			result[p.Name] = v
+		} else if p.Default != nil {
+			result[p.Name] = *p.Default
+		} else {
+			return nil, fmt.Errorf("missing required parameter: --%s", p.Name)
+		}
+	}
+	return result, nil
+}
+
+func (b *B) checkParams(t *Target, path string) []error {
+	var errs []error
+	seen := make(map[string]bool)
+	for i, p := range t.Params {
+		if seen[p.Name] {
+			errs = append(errs, fmt.Errorf("%s.params[%d].name: duplicates parameter '%s'", path, i, p.Name))
+		}
+		if _, ok := b.owner(t).Vars[p.Name]; ok {
+			errs = append(errs, fmt.Errorf("%s.params[%d].name: shadows variable '%s'", path, i, p.Name))
+		}
+		seen[p.Name] = true
+	}
+	return errs
+}
@@ -0,0 +1,59 @@
+package baconfile_test
+
+import (
+	"github.com/troykinsella/bacon/baconfile"
+	"reflect"
+	"testing"
+)
+
+func TestTarget_ParamValues(t *testing.T) {
+	def := "./..."
+	target := &baconfile.Target{
+		Params: []*baconfile.Param{
+			{Name: "pkg", Default: &def},
+			{Name: "tags"},
+		},
+	}
+
+	var tests = []struct {
+		given map[string]string
+		exp   map[string]string
+		err   string
+	}{
+		{
+			map[string]string{"tags": "integration"},
+			map[string]string{"pkg": "./...", "tags": "integration"},
+			"",
+		},
+		{
+			map[string]string{"tags": "", "pkg": "./foo"},
+			map[string]string{"pkg": "./foo", "tags": ""},
+			"",
+		},
+		{
+			map[string]string{},
+			nil,
+			"missing required parameter: --tags",
+		},
+		{
+			map[string]string{"tags": "x", "bogus": "y"},
+			nil,
+			"unknown parameter: --bogus",
+		},
+	}
+
+	for i, test := range tests {
+		r, err := target.ParamValues(test.given)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%d. unexpected error:\nexpected=%s,\nactual=%v\n", i, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d. unexpected error: %s\n", i, err.Error())
+		} else if !reflect.DeepEqual(r, test.exp) {
+			t.Errorf("%d. unexpected result:\nexpected=%#v,\nactual=%#v\n", i, test.exp, r)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package baconfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SchemaID is the JSON Schema dialect of the generated schema.
+const SchemaID = "https://json-schema.org/draft/2020-12/schema"
+
+// definitions are the types that the schema defines once, and refers to.
+var definitions = map[reflect.Type]string{
+	reflect.TypeOf(Target{}):  "target",
+	reflect.TypeOf(Rule{}):    "rule",
+	reflect.TypeOf(Param{}):   "param",
+	reflect.TypeOf(Command{}): "command",
+}
+
+var plainKey = regexp.MustCompile(`^[A-Za-z0-9_/:-]+$`)
+
+// constraints are the parsed schema tag of a field, which is a
+// comma-separated list of "required", "minItems=N", "enum=a|b",
+// "pattern=re", "keyPattern=re", and "type=a|b". A pattern can't contain
+// a comma.
+type constraints struct {
+	required   bool
+	minItems   int
+	enum       []string
+	pattern    string
+	keyPattern string
+	types      []string
+}
+
+func parseConstraints(tag string) constraints {
+	var c constraints
+	if tag == "" {
+		return c
+	}
+	for _, part := range strings.Split(tag, ",") {
+		kv := strings.SplitN(part, "=", 2)
+		switch kv[0] {
+		case "required":
+			c.required = true
+		case "minItems":
+			c.minItems, _ = strconv.Atoi(kv[1])
+		case "enum":
+			c.enum = strings.Split(kv[1], "|")
+		case "pattern":
+			c.pattern = kv[1]
+		case "keyPattern":
+			c.keyPattern = kv[1]
+		case "type":
+			c.types = strings.Split(kv[1], "|")
+		}
+	}
+	return c
+}
+
+// yamlName returns the key of the field in a Baconfile, or an empty string
+// when the field isn't part of a Baconfile.
+func yamlName(f reflect.StructField) string {
+	if !f.IsExported() {
+		return ""
+	}
+	name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
+// Schema returns the JSON Schema of the current version of the Baconfile,
+// which YAML language servers use to validate and complete Baconfiles.
+func Schema() ([]byte, error) {
+	root := structSchema(reflect.TypeOf(B{}))
+	root["$schema"] = SchemaID
+	root["title"] = "Baconfile"
+	root["properties"].(map[string]interface{})["version"].(map[string]interface{})["const"] = Version
+	root["required"] = append([]string{"version"}, root["required"].([]string)...)
+
+	defs := make(map[string]interface{})
+	for t, name := range definitions {
+		defs[name] = structSchema(t)
+	}
+	// A command is also given as just a string
+	defs["command"] = map[string]interface{}{
+		"oneOf": []interface{}{
+			map[string]interface{}{"type": "string", "minLength": 1},
+			defs["command"],
+		},
+	}
+	root["$defs"] = defs
+
+	return json.MarshalIndent(root, "", "  ")
+}
+
+func structSchema(t reflect.Type) map[string]interface{} {
+	props := make(map[string]interface{})
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := yamlName(f)
+		if name == "" {
+			continue
+		}
+
+		c := parseConstraints(f.Tag.Get("schema"))
+		s := typeSchema(f.Type, c)
+		if d := f.Tag.Get("desc"); d != "" {
+			s["description"] = d
+		}
+		props[name] = s
+		if c.required {
+			required = append(required, name)
+		}
+	}
+
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           props,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+func typeSchema(t reflect.Type, c constraints) map[string]interface{} {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if name, ok := definitions[t]; ok {
+		return map[string]interface{}{"$ref": "#/$defs/" + name}
+	}
+
+	s := make(map[string]interface{})
+	switch t.Kind() {
+	case reflect.Bool:
+		s["type"] = "boolean"
+	case reflect.String:
+		s["type"] = "string"
+		if c.required {
+			s["minLength"] = 1
+		}
+		if c.enum != nil {
+			s["enum"] = c.enum
+		}
+		if c.pattern != "" {
+			s["pattern"] = c.pattern
+		}
+	case reflect.Slice:
+		s["type"] = "array"
+		s["items"] = typeSchema(t.Elem(), constraints{})
+		if c.minItems > 0 {
+			s["minItems"] = c.minItems
+		}
+	case reflect.Map:
+		s["type"] = "object"
+		s["additionalProperties"] = typeSchema(t.Elem(), constraints{})
+		if c.keyPattern != "" {
+			s["propertyNames"] = map[string]interface{}{"pattern": c.keyPattern}
+		}
+		if c.minItems > 0 {
+			s["minProperties"] = c.minItems
+		}
+	}
+	if c.types != nil {
+		s["type"] = c.types
+	}
+	return s
+}
+
+// checkSchema checks the value against the constraints of the schema tags
+// of its fields, returning every problem, each prefixed with its YAML path.
+// Abstract targets aren't checked.
+func checkSchema(v reflect.Value, path string) []error {
+	var errs []error
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		if t, ok := v.Interface().(*Target); ok && t.Abstract {
+			return nil
+		}
+		return checkSchema(v.Elem(), path)
+
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			name := yamlName(f)
+			if name == "" {
+				continue
+			}
+			fPath := joinPath(path, name)
+			errs = append(errs, checkConstraints(v.Field(i), fPath, parseConstraints(f.Tag.Get("schema")))...)
+			errs = append(errs, checkSchema(v.Field(i), fPath)...)
+		}
+
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			errs = append(errs, checkSchema(v.Index(i), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+
+	case reflect.Map:
+		for _, k := range sortedKeys(v) {
+			errs = append(errs, checkSchema(v.MapIndex(k), joinPath(path, k.String()))...)
+		}
+	}
+	return errs
+}
+
+func checkConstraints(v reflect.Value, path string, c constraints) []error {
+	fail := func(msg string) []error {
+		return []error{fmt.Errorf("%s: %s", path, msg)}
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		s := v.String()
+		if strings.TrimSpace(s) == "" {
+			if c.required {
+				return fail("is required")
+			}
+			return nil
+		}
+		if c.enum != nil && !contains(c.enum, s) {
+			return fail(fmt.Sprintf("must be one of: %s", strings.Join(c.enum, ", ")))
+		}
+		if c.pattern != "" && !regexp.MustCompile(c.pattern).MatchString(s) {
+			return fail(fmt.Sprintf("must match the pattern %s", c.pattern))
+		}
+
+	case reflect.Slice, reflect.Map:
+		if c.required && v.Len() == 0 && c.minItems == 0 {
+			return fail("is required")
+		}
+		if v.Len() < c.minItems {
+			return fail(fmt.Sprintf("must have at least %d %s", c.minItems, plural(c.minItems, "entry", "entries")))
+		}
+		if v.Kind() == reflect.Map && c.keyPattern != "" {
+			var errs []error
+			re := regexp.MustCompile(c.keyPattern)
+			for _, k := range sortedKeys(v) {
+				if !re.MatchString(k.String()) {
+					errs = append(errs, fmt.Errorf("%s: the name must match the pattern %s", joinPath(path, k.String()), c.keyPattern))
+				}
+			}
+			return errs
+		}
+	}
+	return nil
+}
+
+// joinPath appends the key to the YAML path, quoting keys that aren't
+// plain.
+func joinPath(path string, key string) string {
+	if !plainKey.MatchString(key) {
+		return fmt.Sprintf("%s[%q]", path, key)
+	}
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func sortedKeys(m reflect.Value) []reflect.Value {
+	keys := m.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+	return keys
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+func plural(n int, one string, many string) string {
+	if n == 1 {
+		return one
+	}
+	return many
+}
@@ -0,0 +1,109 @@
+package baconfile_test
+
+import (
+	"encoding/json"
+	"github.com/troykinsella/bacon/baconfile"
+	"reflect"
+	"testing"
+)
+
+func TestSchema(t *testing.T) {
+	out, err := baconfile.Schema()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	var schema map[string]interface{}
+	if err := json.Unmarshal(out, &schema); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	var tests = []struct {
+		path []string
+		exp  interface{}
+	}{
+		{
+			[]string{"properties", "version", "const"},
+			baconfile.Version,
+		},
+		{
+			[]string{"required"},
+			[]interface{}{"version", "targets"},
+		},
+		{
+			[]string{"properties", "targets", "additionalProperties", "$ref"},
+			"#/$defs/target",
+		},
+		{
+			[]string{"$defs", "target", "additionalProperties"},
+			false,
+		},
+		{
+			[]string{"$defs", "target", "properties", "go_packages", "enum"},
+			[]interface{}{"changed", "dependents"},
+		},
+		{
+			[]string{"$defs", "target", "properties", "poll", "type"},
+			[]interface{}{"boolean", "string"},
+		},
+		{
+			[]string{"$defs", "target", "properties", "watch", "description"},
+			"Glob patterns of files to watch",
+		},
+		{
+			[]string{"$defs", "rule", "properties", "command", "minItems"},
+			1.0,
+		},
+		{
+			[]string{"$defs", "param", "required"},
+			[]interface{}{"name"},
+		},
+	}
+
+	for i, test := range tests {
+		var v interface{} = schema
+		for _, key := range test.path {
+			v = v.(map[string]interface{})[key]
+		}
+		if !reflect.DeepEqual(test.exp, v) {
+			t.Errorf("%d. unexpected result:\nexpected=%#v,\nactual=%#v\n", i, test.exp, v)
+		}
+	}
+}
+
+func TestValidate_Paths(t *testing.T) {
+	var tests = []struct {
+		b   string
+		err string
+	}{
+		{
+			"version: \"2\"\n",
+			"malformed Baconfile: targets: must have at least 1 entry",
+		},
+		{
+			"version: \"2\"\ntargets:\n  foo:\n    watch: [ foo ]\n    command: [ ls ]\n    go_packages: all\n",
+			"malformed Baconfile: targets.foo.go_packages: must be one of: changed, dependents",
+		},
+		{
+			"version: \"2\"\ntargets:\n  foo:\n    watch: [ foo ]\n    params: [ { name: a-b } ]\n    command: [ ls ]\n",
+			"malformed Baconfile: targets.foo.params[0].name: must match the pattern ^[A-Za-z_][A-Za-z0-9_]*$",
+		},
+		{
+			"version: \"2\"\ntargets:\n  foo.bar:\n    watch: [ foo ]\n    rules: [ { command: [ ls ] } ]\n",
+			"malformed Baconfile: targets[\"foo.bar\"].rules[0].watch: must have at least 1 entry",
+		},
+		{
+			"version: \"2\"\ntargets:\n  foo:\n    watch: [ foo, \"type:cobol\" ]\n    command: [ ls ]\n",
+			"malformed Baconfile: targets.foo.watch[1]: unknown file type 'cobol' in glob 'type:cobol': expected one of go, js, python, rust, ts",
+		},
+	}
+
+	for i, test := range tests {
+		_, err := baconfile.Unmarshal([]byte(test.b))
+		if err == nil {
+			t.Errorf("%d. expected error:\nexpected=%s,\nactual=nil\n", i, test.err)
+		} else if test.err != err.Error() {
+			t.Errorf("%d. unexpected error:\nexpected=%s,\nactual=%s\n", i, test.err, err.Error())
+		}
+	}
+}
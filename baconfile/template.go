@@ -0,0 +1,95 @@
+package baconfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// template is a starting Baconfile for a type of project, detected by the
+// presence of its marker file.
+type template struct {
+	name   string
+	marker string
+	target func() *Target
+}
+
+// templates are in the order in which projects are detected, so that
+// language-specific templates win over the generic make template.
+var templates = []template{
+	{"go", "go.mod", func() *Target {
+		return &Target{
+			Watch:   []string{"type:go"},
+			Exclude: []string{"**/.*", "vendor"},
+			Command: Commands("go vet ./...", "go test ./..."),
+		}
+	}},
+	{"node", "package.json", func() *Target {
+		return &Target{
+			Watch:   []string{"type:js", "type:ts"},
+			Exclude: []string{"**/.*", "node_modules", "dist", "build", "coverage"},
+			Command: Commands("npm test"),
+		}
+	}},
+	{"python", "pyproject.toml", func() *Target {
+		return &Target{
+			Watch:   []string{"type:python"},
+			Exclude: []string{"**/.*", "**/__pycache__", "venv", "build", "dist"},
+			Command: Commands("python -m pytest"),
+		}
+	}},
+	{"rust", "Cargo.toml", func() *Target {
+		return &Target{
+			Watch:   []string{"type:rust"},
+			Exclude: []string{"**/.*", "target"},
+			Command: Commands("cargo check", "cargo test"),
+		}
+	}},
+	{"make", "Makefile", func() *Target {
+		return &Target{
+			Watch:   []string{"**/*"},
+			Command: Commands("make"),
+		}
+	}},
+}
+
+// TemplateNames lists the names of the project templates.
+func TemplateNames() []string {
+	names := make([]string, len(templates))
+	for i, t := range templates {
+		names[i] = t.name
+	}
+	return names
+}
+
+// Template returns a Baconfile for the named type of project, with a
+// "default" target that honours ignore files.
+func Template(name string) (*B, error) {
+	for _, t := range templates {
+		if t.name == name {
+			return &B{
+				Version:     Version,
+				IgnoreFiles: true,
+				Targets:     map[string]*Target{"default": t.target()},
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown template '%s': expected one of %s", name, strings.Join(TemplateNames(), ", "))
+}
+
+// DetectTemplate returns the name of the template for the project in the
+// directory, according to the files present, or an empty string when the
+// type of project isn't recognized.
+func DetectTemplate(dir string) (string, error) {
+	for _, t := range templates {
+		_, err := os.Stat(filepath.Join(dir, t.marker))
+		if err == nil {
+			return t.name, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+	return "", nil
+}
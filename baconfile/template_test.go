@@ -0,0 +1,57 @@
+package baconfile_test
+
+import (
+	"github.com/troykinsella/bacon/baconfile"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectTemplate(t *testing.T) {
+	var tests = []struct {
+		files []string
+		exp   string
+	}{
+		{[]string{}, ""},
+		{[]string{"go.mod"}, "go"},
+		{[]string{"Makefile", "Cargo.toml"}, "rust"},
+		{[]string{"Makefile", "package.json"}, "node"},
+		{[]string{"pyproject.toml"}, "python"},
+		{[]string{"Makefile"}, "make"},
+	}
+
+	for i, test := range tests {
+		dir := t.TempDir()
+		for _, f := range test.files {
+			if err := os.WriteFile(filepath.Join(dir, f), nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		r, err := baconfile.DetectTemplate(dir)
+		if err != nil {
+			t.Errorf("%d. unexpected error: %s\n", i, err.Error())
+		} else if r != test.exp {
+			t.Errorf("%d. unexpected result:\nexpected=%s,\nactual=%s\n", i, test.exp, r)
+		}
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	for _, name := range baconfile.TemplateNames() {
+		b, err := baconfile.Template(name)
+		if err != nil {
+			t.Errorf("%s. unexpected error: %s\n", name, err.Error())
+			continue
+		}
+		if err := b.Validate(); err != nil {
+			t.Errorf("%s. unexpected error: %s\n", name, err.Error())
+		}
+	}
+
+	_, err := baconfile.Template("cobol")
+	exp := "unknown template 'cobol': expected one of go, node, python, rust, make"
+	if err == nil || err.Error() != exp {
+		t.Errorf("unexpected error:\nexpected=%s,\nactual=%v\n", exp, err)
+	}
+}
@@ -0,0 +1,240 @@
+package baconfile
+
+import (
+	"fmt"
+	"github.com/troykinsella/bacon/util"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const envPrefix = "env:"
+
+var varName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+var argRef = regexp.MustCompile(`^\$([0-9]+)`)
+
+// lookupFunc returns the value of a variable, and whether it's defined.
+type lookupFunc func(name string) (string, bool)
+
+// Interpolate returns a copy of the target in which variable references in
+// the dir, watch, exclude, and command fields are replaced. A reference is
+// one of:
+//
+//	${NAME}              the value of the NAME parameter, or variable
+//	${env:NAME}          the value of the NAME environment variable
+//	${NAME:-default}     the default, when the value is undefined or empty
+//
+// Variables are taken from the overrides, then vars. Parameter values are
+// taken from params, or the parameter's default, and are quoted for the shell
+// when they are referenced in commands. "$${" is replaced with a literal
+// "${", to pass variable references through to the shell.
+//
+// In the watch, exclude, and command fields, $1, $2, and so on, are replaced
+// with the positional arguments. References beyond the given arguments are
+// left as they are. Both kinds of reference are replaced in a single pass, so
+// that values aren't themselves interpolated.
+//
+// Targets of legacy Baconfiles, which predate interpolation, only have their
+// positional arguments replaced, so that their "${...}" references reach the
+// shell.
+func (b *B) Interpolate(t *Target, overrides map[string]string, params map[string]string, args []string) (*Target, error) {
+	return b.interpolateWith(t, overrides, params, args, os.LookupEnv)
+}
+
+// interpolateWith interpolates the target like Interpolate, taking the
+// values of environment variables from getenv.
+func (b *B) interpolateWith(t *Target, overrides map[string]string, params map[string]string, args []string, getenv lookupFunc) (*Target, error) {
+	var lookup, quotedLookup lookupFunc
+	if b.owner(t).Version != Version {
+		if len(overrides) > 0 {
+			return nil, fmt.Errorf("variables require Baconfile version %s: run 'bacon migrate'", Version)
+		}
+	} else {
+		lookup = func(name string) (string, bool) {
+			if p := t.Param(name); p != nil {
+				if v, ok := params[name]; ok {
+					return v, true
+				}
+				if p.Default != nil {
+					return *p.Default, true
+				}
+				return "", true
+			}
+			if v, ok := overrides[name]; ok {
+				return v, true
+			}
+			v, ok := b.owner(t).Vars[name]
+			return v, ok
+		}
+		quotedLookup = func(name string) (string, bool) {
+			v, ok := lookup(name)
+			if ok && t.Param(name) != nil {
+				v = util.ShellQuote(v)
+			}
+			return v, ok
+		}
+	}
+
+	result := *t
+	var err error
+	expand := func(strs []string) []string {
+		if strs == nil || err != nil {
+			return strs
+		}
+		out := make([]string, len(strs))
+		for i, s := range strs {
+			out[i], err = interpolate(s, lookup, getenv, args)
+			if err != nil {
+				return nil
+			}
+		}
+		return out
+	}
+	expandCommands := func(cmds []*Command) []*Command {
+		if cmds == nil || err != nil {
+			return cmds
+		}
+		out := make([]*Command, len(cmds))
+		for i, c := range cmds {
+			out[i] = &Command{Env: c.Env}
+			out[i].Run, err = interpolate(c.Run, quotedLookup, getenv, args)
+			if err != nil {
+				return nil
+			}
+		}
+		return out
+	}
+
+	if result.Dir, err = interpolate(t.Dir, lookup, getenv, nil); err != nil {
+		return nil, err
+	}
+	result.Watch = expand(t.Watch)
+	result.Exclude = expand(t.Exclude)
+	result.Command = expandCommands(t.Command)
+	result.Pass = expandCommands(t.Pass)
+	result.Fail = expandCommands(t.Fail)
+
+	result.Rules = nil
+	for _, r := range t.Rules {
+		result.Rules = append(result.Rules, &Rule{
+			Watch:   expand(r.Watch),
+			Exclude: expand(r.Exclude),
+			Command: expandCommands(r.Command),
+		})
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// interpolate replaces the variable references and positional arguments in
+// the string. Variable references are left as they are when lookup is nil.
+func interpolate(s string, lookup lookupFunc, getenv lookupFunc, args []string) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+
+	var out strings.Builder
+	for len(s) > 0 {
+		i := strings.Index(s, "$")
+		if i < 0 {
+			out.WriteString(s)
+			break
+		}
+		out.WriteString(s[:i])
+		s = s[i:]
+
+		switch {
+		case lookup != nil && strings.HasPrefix(s, "$${"):
+			out.WriteString("${")
+			s = s[3:]
+
+		case lookup != nil && strings.HasPrefix(s, "${"):
+			end := strings.Index(s, "}")
+			if end < 0 {
+				return "", fmt.Errorf("unterminated variable reference: %s", s)
+			}
+			v, err := resolve(s[:end+1], lookup, getenv)
+			if err != nil {
+				return "", err
+			}
+			out.WriteString(v)
+			s = s[end+1:]
+
+		default:
+			m := argRef.FindStringSubmatch(s)
+			index := 0
+			if m != nil {
+				index, _ = strconv.Atoi(m[1])
+			}
+			if index < 1 || index > len(args) {
+				// Not a reference, or one beyond the arguments
+				out.WriteString("$")
+				s = s[1:]
+				continue
+			}
+			out.WriteString(args[index-1])
+			s = s[len(m[0]):]
+		}
+	}
+	return out.String(), nil
+}
+
+// resolve returns the value of a "${...}" variable reference.
+func resolve(ref string, lookup lookupFunc, getenv lookupFunc) (string, error) {
+	body := ref[2 : len(ref)-1]
+
+	name := body
+	def := ""
+	hasDef := false
+	if i := strings.Index(body, ":-"); i >= 0 {
+		name = body[:i]
+		def = body[i+2:]
+		hasDef = true
+	}
+
+	env := strings.HasPrefix(name, envPrefix)
+	if env {
+		name = strings.TrimPrefix(name, envPrefix)
+	}
+	if !varName.MatchString(name) {
+		return "", fmt.Errorf("invalid variable reference: %s", ref)
+	}
+
+	var v string
+	var ok bool
+	if env {
+		v, ok = getenv(name)
+		if !ok && !hasDef {
+			return "", fmt.Errorf("environment variable not set: %s", name)
+		}
+	} else {
+		v, ok = lookup(name)
+		if !ok && !hasDef {
+			return "", fmt.Errorf("undefined variable: %s", name)
+		}
+	}
+
+	if v == "" && hasDef {
+		return def, nil
+	}
+	return v, nil
+}
+
+// anyEnv treats every environment variable as set and empty, so that
+// validating a Baconfile doesn't depend on the environment, which only
+// matters to the target that is run.
+func anyEnv(name string) (string, bool) {
+	return "", true
+}
+
+// escapeRefs escapes the "${" of shell variable references in a legacy
+// Baconfile's field, so that they're passed through to the shell once the
+// Baconfile is migrated.
+func escapeRefs(s string) string {
+	return strings.Replace(s, "${", "$${", -1)
+}
@@ -0,0 +1,178 @@
+package baconfile_test
+
+import (
+	"github.com/troykinsella/bacon/baconfile"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestB_Interpolate(t *testing.T) {
+	_ = os.Setenv("BACON_TEST_HOME", "/home/bacon")
+	defer os.Unsetenv("BACON_TEST_HOME")
+
+	var tests = []struct {
+		vars      map[string]string
+		overrides map[string]string
+		in        string
+		exp       string
+		err       string
+	}{
+		{nil, nil, "make test", "make test", ""},
+		{map[string]string{"SRC": "src"}, nil, "${SRC}/**", "src/**", ""},
+		{map[string]string{"SRC": "src"}, map[string]string{"SRC": "lib"}, "${SRC}/**", "lib/**", ""},
+		{nil, map[string]string{"PKG": "./foo"}, "go test ${PKG}", "go test ./foo", ""},
+		{nil, nil, "${env:BACON_TEST_HOME}/x", "/home/bacon/x", ""},
+		{nil, nil, "${env:BACON_TEST_UNSET:-}", "", ""},
+		{nil, nil, "${env:BACON_TEST_UNSET:-def}", "def", ""},
+		{nil, nil, "${PKG:-./...}", "./...", ""},
+		{map[string]string{"PKG": ""}, nil, "${PKG:-./...}", "./...", ""},
+		{nil, nil, "a $${SHELL_VAR} b", "a ${SHELL_VAR} b", ""},
+		{nil, nil, "go test {packages} $1", "go test {packages} $1", ""},
+		{nil, nil, "${PKG}", "", "undefined variable: PKG"},
+		{nil, nil, "${env:BACON_TEST_UNSET}", "", "environment variable not set: BACON_TEST_UNSET"},
+		{nil, nil, "${PKG", "", "unterminated variable reference: ${PKG"},
+		{nil, nil, "${a b}", "", "invalid variable reference: ${a b}"},
+	}
+
+	for i, test := range tests {
+		b := &baconfile.B{Version: baconfile.Version, Vars: test.vars}
+		in := &baconfile.Target{
+			Dir:     test.in,
+			Watch:   []string{test.in},
+			Command: baconfile.Commands(test.in),
+		}
+
+		out, err := b.Interpolate(in, test.overrides, nil, nil)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%d. unexpected error:\nexpected=%s,\nactual=%v\n", i, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d. unexpected error: %s\n", i, err.Error())
+			continue
+		}
+
+		exp := &baconfile.Target{
+			Dir:     test.exp,
+			Watch:   []string{test.exp},
+			Command: baconfile.Commands(test.exp),
+		}
+		if !reflect.DeepEqual(out, exp) {
+			t.Errorf("%d. unexpected result:\nexpected=%#v,\nactual=%#v\n", i, exp, out)
+		}
+		if in.Watch[0] != test.in {
+			t.Errorf("%d. target was modified: %s\n", i, in.Watch[0])
+		}
+	}
+}
+
+func TestB_Interpolate_Params(t *testing.T) {
+	def := "./..."
+	target := &baconfile.Target{
+		Watch:   []string{"${pkg}/*.go"},
+		Command: baconfile.Commands("go test ${pkg}"),
+		Params:  []*baconfile.Param{{Name: "pkg", Default: &def}},
+	}
+
+	var tests = []struct {
+		params     map[string]string
+		expWatch   string
+		expCommand string
+	}{
+		{nil, "./.../*.go", "go test ./..."},
+		{map[string]string{"pkg": "./foo"}, "./foo/*.go", "go test ./foo"},
+		{map[string]string{"pkg": "a b; rm x"}, "a b; rm x/*.go", "go test 'a b; rm x'"},
+	}
+
+	for i, test := range tests {
+		b := &baconfile.B{Version: baconfile.Version}
+		out, err := b.Interpolate(target, nil, test.params, nil)
+		if err != nil {
+			t.Errorf("%d. unexpected error: %s\n", i, err.Error())
+			continue
+		}
+		if out.Watch[0] != test.expWatch || out.Command[0].Run != test.expCommand {
+			t.Errorf("%d. unexpected result:\nexpected=%s, %s,\nactual=%s, %s\n", i, test.expWatch, test.expCommand, out.Watch[0], out.Command[0].Run)
+		}
+	}
+}
+
+func TestB_Interpolate_Args(t *testing.T) {
+	def := "./..."
+	params := []*baconfile.Param{{Name: "pkg", Default: &def}}
+
+	var tests = []struct {
+		version    string
+		command    string
+		params     map[string]string
+		args       []string
+		expCommand string
+	}{
+		{baconfile.Version, "go test $1 $2", nil, []string{"-v", "-run"}, "go test -v -run"},
+		{baconfile.Version, "go test $1 $3", nil, []string{"-v"}, "go test -v $3"},
+		{baconfile.Version, "echo $$1 $ $HOME", nil, []string{"a"}, "echo $a $ $HOME"},
+		{baconfile.Version, "go test ${pkg} $1", map[string]string{"pkg": "$1"}, []string{"-v"}, "go test '$1' -v"},
+		{baconfile.Version, "echo $${1} $1", nil, []string{"a"}, "echo ${1} a"},
+		{baconfile.LegacyVersion, "echo ${HOME} $1", nil, []string{"a"}, "echo ${HOME} a"},
+	}
+
+	for i, test := range tests {
+		b := &baconfile.B{Version: test.version}
+		target := &baconfile.Target{
+			Command: baconfile.Commands(test.command),
+			Params:  params,
+		}
+		out, err := b.Interpolate(target, nil, test.params, test.args)
+		if err != nil {
+			t.Errorf("%d. unexpected error: %s\n", i, err.Error())
+			continue
+		}
+		if out.Command[0].Run != test.expCommand {
+			t.Errorf("%d. unexpected result:\nexpected=%s,\nactual=%s\n", i, test.expCommand, out.Command[0].Run)
+		}
+	}
+}
+
+func TestLoad_UnsetEnv(t *testing.T) {
+	dir := writeBaconfiles(t, map[string]string{
+		"Baconfile": `---
+version: "2"
+targets:
+  test: { watch: [x], command: [ "go test" ] }
+  deploy: { watch: [ "${env:BACON_TEST_UNSET}/x" ], command: [ "deploy ${env:BACON_TEST_UNSET}" ] }
+  broken: { watch: [x], command: [ "${env:BACON_TEST_UNSET" ] }
+`,
+	})
+
+	_, err := baconfile.Load(filepath.Join(dir, "Baconfile"))
+	exp := "malformed Baconfile: targets.broken: unterminated variable reference: ${env:BACON_TEST_UNSET"
+	if err == nil || err.Error() != exp {
+		t.Fatalf("unexpected error:\nexpected=%s,\nactual=%v\n", exp, err)
+	}
+
+	dir = writeBaconfiles(t, map[string]string{
+		"Baconfile": `---
+version: "2"
+targets:
+  test: { watch: [x], command: [ "go test" ] }
+  deploy: { watch: [ "${env:BACON_TEST_UNSET}/x" ], command: [ "deploy ${env:BACON_TEST_UNSET}" ] }
+`,
+	})
+
+	b, err := baconfile.Load(filepath.Join(dir, "Baconfile"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if _, err := b.Interpolate(b.Targets["test"], nil, nil, nil); err != nil {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+	_, err = b.Interpolate(b.Targets["deploy"], nil, nil, nil)
+	exp = "environment variable not set: BACON_TEST_UNSET"
+	if err == nil || err.Error() != exp {
+		t.Errorf("unexpected error:\nexpected=%s,\nactual=%v\n", exp, err)
+	}
+}
@@ -0,0 +1,380 @@
+package baconfile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	yaml3 "gopkg.in/yaml.v3"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LegacyVersion is the version of the original schema, in which targets are
+// under "target" rather than "targets". A Baconfile without a version is a
+// legacy Baconfile.
+const LegacyVersion = "1.0"
+
+// legacy is the legacy schema, which is decoded leniently, ignoring unknown
+// fields, as it was before version 2.
+type legacy struct {
+	Version string                   `yaml:"version"`
+	Target  map[string]*legacyTarget `yaml:"target"`
+}
+
+type legacyTarget struct {
+	Watch   []string `yaml:"watch"`
+	Exclude []string `yaml:"exclude"`
+	Dir     string   `yaml:"dir"`
+	Command []string `yaml:"command"`
+	Pass    []string `yaml:"pass"`
+	Fail    []string `yaml:"fail"`
+	Shell   string   `yaml:"shell"`
+}
+
+var linePrefix = regexp.MustCompile(`^line (\d+): `)
+
+var unknownField = regexp.MustCompile(`^line (\d+): field (\S+) not found in type baconfile\.(\w+)$`)
+
+// typeNames describes the Baconfile's types in errors.
+var typeNames = map[string]string{
+	"B":      "the Baconfile root",
+	"Target": "a target",
+	"Rule":   "a rule",
+	"Param":  "a parameter",
+	"plain":  "a command",
+}
+
+// decode strictly decodes the Baconfile in the format according to its
+// version, failing on unknown fields.
+func decode(in []byte, format Format) (*B, error) {
+	in, err := toYAML(in, format)
+	if err != nil {
+		return nil, err
+	}
+	// Lines of the converted YAML don't correspond to those of other formats
+	lines := format == YAML
+
+	var v struct {
+		Version string `yaml:"version"`
+	}
+	if err := yaml.Unmarshal(in, &v); err != nil {
+		return nil, err
+	}
+
+	switch v.Version {
+	case "", "1", LegacyVersion:
+		return decodeLegacy(in)
+
+	case Version:
+		var b B
+		if err := yaml.UnmarshalStrict(in, &b); err != nil {
+			return nil, strictError(err, in, lines)
+		}
+		return &b, nil
+	}
+
+	return nil, errMalformed(fmt.Sprintf("unsupported version: %s", v.Version))
+}
+
+// decodeLegacy decodes a legacy Baconfile, failing on the fields that were
+// added in version 2, but ignoring unknown fields.
+func decodeLegacy(in []byte) (*B, error) {
+	if err := checkLegacyFields(in); err != nil {
+		return nil, err
+	}
+
+	var l legacy
+	if err := yaml.Unmarshal(in, &l); err != nil {
+		return nil, err
+	}
+
+	b := &B{Version: l.Version}
+	if l.Target != nil {
+		b.Targets = make(map[string]*Target)
+	}
+	for name, lt := range l.Target {
+		if lt == nil {
+			lt = &legacyTarget{}
+		}
+		b.Targets[name] = &Target{
+			Watch:   lt.Watch,
+			Exclude: lt.Exclude,
+			Dir:     lt.Dir,
+			Command: Commands(lt.Command...),
+			Pass:    Commands(lt.Pass...),
+			Fail:    Commands(lt.Fail...),
+			Shell:   lt.Shell,
+		}
+	}
+	return b, nil
+}
+
+// checkLegacyFields fails on the fields of the legacy Baconfile that are
+// only supported by version 2.
+func checkLegacyFields(in []byte) error {
+	var root map[interface{}]interface{}
+	if err := yaml.Unmarshal(in, &root); err != nil {
+		return err
+	}
+
+	if _, ok := root["targets"]; ok {
+		return errMalformed(fmt.Sprintf("'targets' requires version %s: use 'target', or run 'bacon migrate'", Version))
+	}
+	rootFields := fieldNames(reflect.TypeOf(B{}))
+	for _, key := range yamlKeys(root) {
+		if key != "version" && key != "target" && contains(rootFields, key) {
+			return errRequiresVersion(key)
+		}
+	}
+
+	targets, _ := root["target"].(map[interface{}]interface{})
+	targetFields := fieldNames(reflect.TypeOf(Target{}))
+	legacyFields := fieldNames(reflect.TypeOf(legacyTarget{}))
+	for _, name := range yamlKeys(targets) {
+		t, _ := targets[name].(map[interface{}]interface{})
+		for _, key := range yamlKeys(t) {
+			if contains(targetFields, key) && !contains(legacyFields, key) {
+				return errRequiresVersion(fmt.Sprintf("target.%s.%s", name, key))
+			}
+		}
+	}
+	return nil
+}
+
+func errRequiresVersion(path string) error {
+	return errMalformed(fmt.Sprintf("%s: requires version %s: run 'bacon migrate'", path, Version))
+}
+
+// fieldNames returns the keys of the struct's fields in a Baconfile.
+func fieldNames(t reflect.Type) []string {
+	var result []string
+	for i := 0; i < t.NumField(); i++ {
+		if name := yamlName(t.Field(i)); name != "" {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+// yamlKeys returns the string keys of the decoded YAML map, sorted.
+func yamlKeys(m map[interface{}]interface{}) []string {
+	var result []string
+	for k := range m {
+		if s, ok := k.(string); ok {
+			result = append(result, s)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// strictError rewords unknown field errors in terms of the Baconfile. Unless
+// lines is true, line numbers are replaced with the YAML path of the map in
+// which the field appears, since they're those of the Baconfile as converted
+// to YAML.
+func strictError(err error, in []byte, lines bool) error {
+	var te *yaml.TypeError
+	if !errors.As(err, &te) {
+		return err
+	}
+
+	var paths map[string]string
+	if !lines {
+		paths = keyPaths(in)
+	}
+
+	msgs := make([]string, len(te.Errors))
+	for i, e := range te.Errors {
+		m := unknownField.FindStringSubmatch(e)
+		if m != nil {
+			in := typeNames[m[3]]
+			if in == "" {
+				in = m[3]
+			}
+			e = fmt.Sprintf("line %s: unknown field '%s' in %s", m[1], m[2], in)
+		}
+		if !lines {
+			e = linePrefix.ReplaceAllStringFunc(e, func(prefix string) string {
+				if path := paths[linePrefix.FindStringSubmatch(prefix)[1]]; path != "" {
+					return path + ": "
+				}
+				return ""
+			})
+		}
+		msgs[i] = e
+	}
+	return errMalformed(strings.Join(msgs, "; "))
+}
+
+// keyPaths maps the numbers of the lines of the YAML document on which map
+// keys appear to the YAML paths of their maps.
+func keyPaths(in []byte) map[string]string {
+	result := make(map[string]string)
+
+	var walk func(n *yaml3.Node, path string)
+	walk = func(n *yaml3.Node, path string) {
+		switch n.Kind {
+		case yaml3.DocumentNode:
+			for _, c := range n.Content {
+				walk(c, path)
+			}
+		case yaml3.MappingNode:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				key := n.Content[i]
+				result[strconv.Itoa(key.Line)] = path
+				walk(n.Content[i+1], joinPath(path, key.Value))
+			}
+		case yaml3.SequenceNode:
+			for i, c := range n.Content {
+				walk(c, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	}
+
+	var doc yaml3.Node
+	if err := yaml3.Unmarshal(in, &doc); err == nil {
+		walk(&doc, "")
+	}
+	return result
+}
+
+// Migrate rewrites a legacy Baconfile to the current schema, preserving its
+// comments and layout. Since legacy Baconfiles aren't interpolated, "${" is
+// escaped as "$${" in the fields that now are. Included Baconfiles are left
+// as they are.
+func Migrate(in []byte) ([]byte, error) {
+	if _, err := decode(in, YAML); err != nil {
+		return nil, err
+	}
+
+	var doc yaml3.Node
+	if err := yaml3.Unmarshal(in, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml3.MappingNode {
+		return nil, errMalformed("expected a map at the root")
+	}
+	root := doc.Content[0]
+
+	version := mapValue(root, "version")
+	if version != nil && version.Value == Version {
+		return nil, fmt.Errorf("baconfile is already version %s", Version)
+	}
+	if version == nil {
+		version = &yaml3.Node{Kind: yaml3.ScalarNode}
+		key := &yaml3.Node{Kind: yaml3.ScalarNode, Value: "version"}
+		if len(root.Content) > 0 {
+			// Keep a leading comment at the top
+			key.HeadComment = root.Content[0].HeadComment
+			root.Content[0].HeadComment = ""
+		}
+		root.Content = append([]*yaml3.Node{key, version}, root.Content...)
+	}
+	version.Value = Version
+	version.Tag = "!!str"
+	version.Style = yaml3.DoubleQuotedStyle
+
+	for i := 0; i < len(root.Content); i += 2 {
+		if root.Content[i].Value == "target" {
+			root.Content[i].Value = "targets"
+			escapeTargetNodes(root.Content[i+1])
+		}
+	}
+
+	var out bytes.Buffer
+	enc := yaml3.NewEncoder(&out)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+
+	// Unknown fields, which legacy Baconfiles ignore, fail version 2
+	if _, err := decode(out.Bytes(), YAML); err != nil {
+		return nil, fmt.Errorf("migrated Baconfile: %s", err.Error())
+	}
+	return out.Bytes(), nil
+}
+
+// MigrateAs rewrites a legacy Baconfile in the format to the current schema.
+// YAML Baconfiles keep their comments and layout, while others are
+// rewritten as MarshalAs writes them.
+func MigrateAs(in []byte, format Format) ([]byte, error) {
+	if format == YAML {
+		return Migrate(in)
+	}
+
+	b, err := decode(in, format)
+	if err != nil {
+		return nil, err
+	}
+	if b.Version == Version {
+		return nil, fmt.Errorf("baconfile is already version %s", Version)
+	}
+	for _, t := range b.Targets {
+		escapeTarget(t)
+	}
+	return b.encode(format)
+}
+
+// interpolatedFields are the fields of a legacy target that are interpolated
+// once the target is migrated.
+var interpolatedFields = []string{"dir", "watch", "exclude", "command", "pass", "fail"}
+
+// escapeTargetNodes escapes the shell variable references of the interpolated
+// fields of each target in the legacy targets node.
+func escapeTargetNodes(targets *yaml3.Node) {
+	if targets.Kind != yaml3.MappingNode {
+		return
+	}
+	for i := 1; i < len(targets.Content); i += 2 {
+		for _, field := range interpolatedFields {
+			if v := mapValue(targets.Content[i], field); v != nil {
+				escapeNode(v)
+			}
+		}
+	}
+}
+
+func escapeNode(n *yaml3.Node) {
+	switch n.Kind {
+	case yaml3.ScalarNode:
+		n.Value = escapeRefs(n.Value)
+	case yaml3.SequenceNode:
+		for _, item := range n.Content {
+			escapeNode(item)
+		}
+	}
+}
+
+// escapeTarget escapes the shell variable references of the interpolated
+// fields of the legacy target.
+func escapeTarget(t *Target) {
+	t.Dir = escapeRefs(t.Dir)
+	for _, globs := range [][]string{t.Watch, t.Exclude} {
+		for i, g := range globs {
+			globs[i] = escapeRefs(g)
+		}
+	}
+	for _, cmds := range [][]*Command{t.Command, t.Pass, t.Fail} {
+		for _, c := range cmds {
+			c.Run = escapeRefs(c.Run)
+		}
+	}
+}
+
+// mapValue returns the value of the key in the mapping node, or nil.
+func mapValue(m *yaml3.Node, key string) *yaml3.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package baconfile_test
+
+import (
+	"github.com/troykinsella/bacon/baconfile"
+	"testing"
+)
+
+func TestUnmarshal_Version(t *testing.T) {
+	var tests = []struct {
+		b   string
+		err string
+	}{
+		{`--- { version: "1.0", target: { foo: { watch: [x], command: [y] } } }`, ""},
+		{`--- { version: "1.0", target: { foo: { watch: [x], command: [y], wach: [x] } } }`, ""},
+		{`--- { version: "2", targets: { foo: { watch: [x], command: [y] } } }`, ""},
+		{
+			`--- { version: "2", target: { foo: { watch: [x], command: [y] } } }`,
+			"malformed Baconfile: line 1: unknown field 'target' in the Baconfile root",
+		},
+		{
+			`--- { targets: { foo: { watch: [x], command: [y] } } }`,
+			"malformed Baconfile: 'targets' requires version 2: use 'target', or run 'bacon migrate'",
+		},
+		{
+			`--- { version: "3", targets: {} }`,
+			"malformed Baconfile: unsupported version: 3",
+		},
+		{
+			"---\nversion: \"2\"\ntargets:\n  foo:\n    watch: [x]\n    comand: [y]\n    rules:\n      - { watch: [x], command: [{ run: y, evn: {} }] }\n",
+			"malformed Baconfile: line 6: unknown field 'comand' in a target; line 8: unknown field 'evn' in a command",
+		},
+	}
+
+	for i, test := range tests {
+		_, err := baconfile.Unmarshal([]byte(test.b))
+		if test.err == "" {
+			if err != nil {
+				t.Errorf("%d. unexpected error: %s\n", i, err.Error())
+			}
+		} else if err == nil || err.Error() != test.err {
+			t.Errorf("%d. unexpected error:\nexpected=%s,\nactual=%v\n", i, test.err, err)
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	var tests = []struct {
+		in  string
+		exp string
+		err string
+	}{
+		{
+			`# Build things
+version: "1.0"
+target:
+  default:
+    watch: [ "**/*.go" ] # sources
+    command: [ "go test ./..." ]
+`,
+			`# Build things
+version: "2"
+targets:
+  default:
+    watch: ["**/*.go"] # sources
+    command: ["go test ./..."]
+`,
+			"",
+		},
+		{
+			`# Build things
+
+target: { default: { watch: [x], command: [y] } }
+`,
+			`# Build things
+
+version: "2"
+targets: {default: {watch: [x], command: [y]}}
+`,
+			"",
+		},
+		{
+			`target: { default: { watch: [x], command: [y] } }`,
+			`version: "2"
+targets: {default: {watch: [x], command: [y]}}
+`,
+			"",
+		},
+		{
+			`target: { default: { dir: "${HOME}/x", watch: [x], command: ["echo ${HOME} $${A}"], pass: ["${B}"] } }`,
+			`version: "2"
+targets: {default: {dir: "$${HOME}/x", watch: [x], command: ["echo $${HOME} $$${A}"], pass: ["$${B}"]}}
+`,
+			"",
+		},
+		{
+			`{ version: "2", targets: { default: { watch: [x], command: [y] } } }`,
+			"",
+			"baconfile is already version 2",
+		},
+		{
+			`{ target: { default: { wach: [x], watch: [x], command: [y] } } }`,
+			"",
+			"migrated Baconfile: malformed Baconfile: line 1: unknown field 'wach' in a target",
+		},
+		{
+			`{ target: { default: { watch: [x], command: [y], rules: [] } } }`,
+			"",
+			"malformed Baconfile: target.default.rules: requires version 2: run 'bacon migrate'",
+		},
+	}
+
+	for i, test := range tests {
+		out, err := baconfile.Migrate([]byte(test.in))
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%d. unexpected error:\nexpected=%s,\nactual=%v\n", i, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d. unexpected error: %s\n", i, err.Error())
+		} else if string(out) != test.exp {
+			t.Errorf("%d. unexpected result:\nexpected=%s,\nactual=%s\n", i, test.exp, out)
+		}
+		if _, err := baconfile.Unmarshal(out); err != nil {
+			t.Errorf("%d. unexpected error in migrated Baconfile: %s\n", i, err.Error())
+		}
+	}
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"fmt"
+	"github.com/troykinsella/bacon/baconfile"
+	"github.com/troykinsella/bacon/gopackages"
+	"github.com/urfave/cli"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const completeCommand = "__complete"
+
+// Completion scripts call "bacon __complete" with the words of the command
+// line after the program name, the last being the word being completed,
+// and offer the candidates that it prints. When there are none, the bash and
+// zsh scripts complete file names.
+var completionScripts = map[string]string{
+	"bash": `# bash completion for {{name}}
+_{{name}}() {
+    local cur words cword
+    if declare -F _get_comp_words_by_ref >/dev/null; then
+        _get_comp_words_by_ref -n =: cur words cword
+    else
+        cur="${COMP_WORDS[COMP_CWORD]}"
+        words=("${COMP_WORDS[@]}")
+        cword=$COMP_CWORD
+    fi
+
+    local IFS=$'\n'
+    COMPREPLY=( $("${words[0]}" {{complete}} "${words[@]:1:cword}" 2>/dev/null) )
+    if [ ${#COMPREPLY[@]} -eq 0 ]; then
+        COMPREPLY=( $(compgen -f -- "$cur") )
+    elif declare -F __ltrim_colon_completions >/dev/null; then
+        __ltrim_colon_completions "$cur"
+    fi
+}
+complete -F _{{name}} {{name}}
+`,
+	"zsh": `#compdef {{name}}
+_{{name}}() {
+    local -a candidates
+    candidates=("${(@f)$("${words[1]}" {{complete}} "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+    candidates=(${candidates:#})
+    if (( ${#candidates} )); then
+        compadd -Q -- "${candidates[@]}"
+    else
+        _files
+    fi
+}
+compdef _{{name}} {{name}}
+`,
+	"fish": `# fish completion for {{name}}
+function __{{name}}_complete
+    set -l words (commandline -opc) (commandline -ct)
+    $words[1] {{complete}} $words[2..-1] 2>/dev/null
+end
+complete -c {{name}} -f -a '(__{{name}}_complete)'
+`,
+}
+
+func completionShells() []string {
+	var shells []string
+	for s := range completionScripts {
+		shells = append(shells, s)
+	}
+	sort.Strings(shells)
+	return shells
+}
+
+func newCompletionCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "completion",
+		Usage:     "Print a shell completion script, for bash, zsh, or fish",
+		ArgsUsage: "<shell>",
+		Description: `Completes commands, options, Baconfile target names, and target parameters.
+   To enable completion, add one of these to your shell's startup file:
+
+     source <(bacon completion bash)       # ~/.bashrc
+     source <(bacon completion zsh)        # ~/.zshrc
+     bacon completion fish | source        # ~/.config/fish/config.fish`,
+		Action: func(c *cli.Context) error {
+			script, ok := completionScripts[c.Args().First()]
+			if !ok {
+				return cli.NewExitError(fmt.Sprintf("expected a shell: %s", strings.Join(completionShells(), ", ")), 1)
+			}
+
+			script = strings.NewReplacer(
+				"{{name}}", c.App.Name,
+				"{{complete}}", completeCommand,
+			).Replace(script)
+			_, err := fmt.Fprint(c.App.Writer, script)
+			return err
+		},
+	}
+}
+
+func newCompleteCommand() *cli.Command {
+	return &cli.Command{
+		Name:            completeCommand,
+		Hidden:          true,
+		SkipFlagParsing: true,
+		Action: func(c *cli.Context) error {
+			for _, s := range completions(c.App, c.Args()) {
+				_, _ = fmt.Fprintln(c.App.Writer, s)
+			}
+			return nil
+		},
+	}
+}
+
+// completions returns the candidates for the last of the words, which follow
+// the program name on the command line.
+func completions(app *cli.App, words []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	cur := words[len(words)-1]
+	prev := words[:len(words)-1]
+
+	// Find the command, and the words that follow it
+	var cmd *cli.Command
+	flags := app.Flags
+	var rest []string
+	for i := 0; i < len(prev); i++ {
+		switch {
+		case cmd != nil:
+			rest = append(rest, prev[i])
+		case strings.HasPrefix(prev[i], "-"):
+			if takesValue(flags, prev[i]) {
+				i++
+			}
+		default:
+			cmd = app.Command(prev[i])
+			if cmd == nil {
+				return nil
+			}
+			flags = cmd.Flags
+		}
+	}
+
+	if len(prev) > 0 && takesValue(flags, prev[len(prev)-1]) && !pastDashes(flags, rest) {
+		return filterPrefix(flagValues(flagName(prev[len(prev)-1]), cur), cur)
+	}
+
+	var result []string
+	switch {
+	case cmd == nil && strings.HasPrefix(cur, "-"):
+		result = flagNames(app.Flags)
+	case cmd == nil:
+		for _, c := range app.VisibleCommands() {
+			result = append(result, c.Names()...)
+		}
+	case cmd.Name == "completion":
+		if len(rest) == 0 {
+			result = completionShells()
+		}
+	case cmd.Name == "run" || cmd.Name == "explain":
+		result = targetCompletions(cmd, rest, cur)
+	case strings.HasPrefix(cur, "-"):
+		result = flagNames(cmd.Flags)
+	}
+	return filterPrefix(result, cur)
+}
+
+// targetCompletions completes the target name of the run and explain
+// commands, or their options, including the parameters of the target. After
+// "--", only the target name is completed.
+func targetCompletions(cmd *cli.Command, rest []string, cur string) []string {
+	var bfPath, targetName string
+	dashes := false
+	for i := 0; i < len(rest); i++ {
+		w := rest[i]
+		switch {
+		case dashes:
+			if targetName == "" {
+				targetName = w
+			}
+		case w == "--":
+			dashes = true
+		case strings.HasPrefix(w, "-"):
+			value := ""
+			if eq := strings.Index(w, "="); eq >= 0 {
+				value = w[eq+1:]
+			} else if takesValue(cmd.Flags, w) && i+1 < len(rest) {
+				value = rest[i+1]
+				i++
+			}
+			if flagName(w) == "baconfile" {
+				bfPath = value
+			}
+		case targetName == "":
+			targetName = w
+		}
+	}
+
+	option := !dashes && strings.HasPrefix(cur, "-")
+	if targetName != "" && !option {
+		return nil
+	}
+
+	bf, err := findBaconfile(bfPath)
+	if err != nil {
+		if option {
+			return flagNames(cmd.Flags)
+		}
+		return nil
+	}
+
+	if !option {
+		var names []string
+		for name, t := range bf.Targets {
+			if !t.Abstract {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	result := flagNames(cmd.Flags)
+	if t := bf.Targets[targetName]; t != nil {
+		for _, p := range t.Params {
+			result = append(result, "--"+p.Name)
+		}
+	}
+	return result
+}
+
+// pastDashes answers whether the words that follow a command end past a
+// "--", after which words are arguments rather than options.
+func pastDashes(flags []cli.Flag, words []string) bool {
+	for i := 0; i < len(words); i++ {
+		switch {
+		case words[i] == "--":
+			return true
+		case takesValue(flags, words[i]):
+			i++
+		}
+	}
+	return false
+}
+
+// flagNames returns the options of the flags, such as "-b" and "--baconfile".
+func flagNames(flags []cli.Flag) []string {
+	var result []string
+	for _, f := range flags {
+		for _, name := range strings.Split(f.GetName(), ",") {
+			name = strings.TrimSpace(name)
+			if len(name) == 1 {
+				result = append(result, "-"+name)
+			} else {
+				result = append(result, "--"+name)
+			}
+		}
+	}
+	return result
+}
+
+// flagName returns the long name of the option's flag, such as "baconfile"
+// for "-b", or the option's name without dashes when it isn't known.
+func flagName(option string) string {
+	name := strings.TrimLeft(option, "-")
+	if eq := strings.Index(name, "="); eq >= 0 {
+		name = name[:eq]
+	}
+	switch name {
+	case baconFile:
+		return "baconfile"
+	case projectTemplate:
+		return "template"
+	}
+	return name
+}
+
+// isFlag answers whether the option is one of the flags, given with or
+// without a "=value".
+func isFlag(flags []cli.Flag, option string) bool {
+	if !strings.HasPrefix(option, "-") {
+		return false
+	}
+	name := strings.TrimLeft(option, "-")
+	if eq := strings.Index(name, "="); eq >= 0 {
+		name = name[:eq]
+	}
+	for _, f := range flags {
+		for _, n := range strings.Split(f.GetName(), ",") {
+			if strings.TrimSpace(n) == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// takesValue answers whether the option is one of the flags, and takes its
+// value from the next word.
+func takesValue(flags []cli.Flag, option string) bool {
+	if strings.Contains(option, "=") {
+		return false
+	}
+	name := strings.TrimLeft(option, "-")
+	for _, f := range flags {
+		for _, n := range strings.Split(f.GetName(), ",") {
+			if strings.TrimSpace(n) != name {
+				continue
+			}
+			df, ok := f.(cli.DocGenerationFlag)
+			return ok && df.TakesValue()
+		}
+	}
+	return false
+}
+
+// flagValues returns the candidate values of the named flag.
+func flagValues(name string, cur string) []string {
+	switch name {
+	case "baconfile", statusFile:
+		return fileNames(cur)
+	case "template":
+		return append(baconfile.TemplateNames(), autoTemplate)
+	case fileFormat:
+		var result []string
+		for _, f := range baconfile.Formats {
+			result = append(result, string(f))
+		}
+		return result
+	case goPackages:
+		return []string{gopackages.ModeChanged, gopackages.ModeDependents}
+	case color:
+		return []string{colorAuto, colorAlways, colorNever}
+	}
+	return nil
+}
+
+// fileNames returns the paths that start with the prefix, with a trailing
+// separator on directories.
+func fileNames(prefix string) []string {
+	matches, _ := filepath.Glob(prefix + "*")
+	for i, m := range matches {
+		if fi, err := os.Stat(m); err == nil && fi.IsDir() {
+			matches[i] = m + string(filepath.Separator)
+		}
+	}
+	return matches
+}
+
+func filterPrefix(list []string, prefix string) []string {
+	var result []string
+	for _, s := range list {
+		if strings.HasPrefix(s, prefix) {
+			result = append(result, s)
+		}
+	}
+	return result
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompletions(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"Baconfile": `---
+version: "2"
+targets:
+  base: { abstract: true, watch: [x], command: [y] }
+  lint: { extends: base }
+  test:
+    extends: base
+    params: [ { name: pkg }, { name: race, default: "false" } ]
+`,
+	})
+	bf := filepath.Join(dir, "Baconfile")
+	missing := filepath.Join(dir, "missing")
+	app := newCliApp()
+
+	var tests = []struct {
+		words []string
+		exp   []string
+	}{
+		// Commands and their options
+		{[]string{""}, []string{"command", "list", "init", "run", "migrate", "schema", "validate", "explain", "targets", "completion"}},
+		{[]string{"ru"}, []string{"run"}},
+		{[]string{"--show"}, []string{"--show-output"}},
+		{[]string{"-o", "--color", "always", "ta"}, []string{"targets"}},
+		{[]string{"nope", ""}, nil},
+		{[]string{"init", "--f"}, []string{"--format", "--force"}},
+		{[]string{"completion", ""}, []string{"bash", "fish", "zsh"}},
+		{[]string{"completion", "bash", ""}, nil},
+
+		// Flag values
+		{[]string{"--color", ""}, []string{"auto", "always", "never"}},
+		{[]string{"--go-packages", "d"}, []string{"dependents"}},
+		{[]string{"init", "--format", "t"}, []string{"toml"}},
+		{[]string{"init", "--template", "au"}, []string{"auto"}},
+		{[]string{"run", "-b", filepath.Join(dir, "Bacon")}, []string{bf}},
+		{[]string{"run", "--var", ""}, nil},
+
+		// Target names
+		{[]string{"run", "-b", bf, ""}, []string{"lint", "test"}},
+		{[]string{"explain", "--baconfile", bf, "t"}, []string{"test"}},
+		{[]string{"run", "--baconfile=" + bf, "--no-reload", ""}, []string{"lint", "test"}},
+		{[]string{"run", "-b", bf, "test", ""}, nil},
+		{[]string{"run", "-b", missing, ""}, nil},
+
+		// Target parameters
+		{[]string{"run", "-b", bf, "test", "--"}, []string{"--baconfile", "--var", "--no-reload", "--pkg", "--race"}},
+		{[]string{"run", "-b", bf, "test", "--p"}, []string{"--pkg"}},
+		{[]string{"run", "-b", bf, "test", "--pkg", "x", "--r"}, []string{"--race"}},
+		{[]string{"run", "-b", bf, "lint", "--p"}, nil},
+		{[]string{"run", "-b", missing, "test", "--n"}, []string{"--no-reload"}},
+
+		// After "--", words are arguments
+		{[]string{"run", "-b", bf, "--", "l"}, []string{"lint"}},
+		{[]string{"run", "-b", bf, "--", "test", "--p"}, nil},
+		{[]string{"run", "-b", bf, "test", "--", "-b", ""}, nil},
+	}
+
+	for i, test := range tests {
+		r := completions(app, test.words)
+		if strings.Join(r, " ") != strings.Join(test.exp, " ") {
+			t.Errorf("%d. unexpected result:\nexpected=%v,\nactual=%v\n", i, test.exp, r)
+		}
+	}
+}
+
+func TestTargetCompletions(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"Baconfile": `--- { version: "2", targets: { a: { watch: [x], command: [y], params: [ { name: p } ] } } }`,
+	})
+	bf := filepath.Join(dir, "Baconfile")
+	cmd := newRunCommand()
+
+	var tests = []struct {
+		rest []string
+		cur  string
+		exp  []string
+	}{
+		{[]string{"-b", bf}, "", []string{"a"}},
+		{[]string{"-b", bf}, "-", []string{"-b", "--baconfile", "--var", "--no-reload"}},
+		{[]string{"-b", bf, "a"}, "-", []string{"-b", "--baconfile", "--var", "--no-reload", "--p"}},
+		{[]string{"-b", bf, "a"}, "", nil},
+		{[]string{"-b", bf, "--"}, "-", []string{"a"}},
+		{[]string{"-b", bf, "--", "a"}, "-", nil},
+	}
+
+	for i, test := range tests {
+		r := targetCompletions(cmd, test.rest, test.cur)
+		if strings.Join(r, " ") != strings.Join(test.exp, " ") {
+			t.Errorf("%d. unexpected result:\nexpected=%v,\nactual=%v\n", i, test.exp, r)
+		}
+	}
+}
@@ -3,55 +3,103 @@ package executor
 import (
 	"bytes"
 	"fmt"
+	"github.com/troykinsella/bacon/gopackages"
+	"github.com/troykinsella/bacon/util"
 	"io"
 	"os"
 	"os/exec"
+	"strings"
 	"sync"
 	"time"
 )
 
 const (
-	defaultShell = "bash"
+	// DefaultShell interprets commands when no shell is given.
+	DefaultShell = "bash"
+
+	// packagesPlaceholder is replaced in commands with the affected Go
+	// packages when UseGoPackages is enabled.
+	packagesPlaceholder = "{packages}"
+	allPackages         = "./..."
 )
 
 type E struct {
 	target       string
-	commands     []string
-	passCommands []string
-	failCommands []string
+	commands     []*Command
+	passCommands []*Command
+	failCommands []*Command
+	rules        []*rule
 
 	shell      string
 	dir        string
 	showOutput bool
+	goPackages *gopackages.P
+	env        []string
 
 	out io.Writer
 	err io.Writer
 
-	mu      *sync.Mutex
-	first   bool
-	passing bool
+	mu         *sync.Mutex
+	first      bool
+	passing    bool
+	runCount   int
+	passStreak int
+}
+
+// Selector decides whether a changed file is selected, such as by a set of
+// globs.
+type Selector interface {
+	Selected(path string) (bool, error)
+}
+
+type rule struct {
+	selector Selector
+	commands []*Command
+}
+
+// Command is a shell command, along with environment variables, in
+// KEY=VALUE form, that apply to it alone.
+type Command struct {
+	Run string
+	Env []string
+}
+
+// Commands creates commands without environment variables of their own.
+func Commands(runs []string) []*Command {
+	if runs == nil {
+		return nil
+	}
+	result := make([]*Command, len(runs))
+	for i, r := range runs {
+		result[i] = &Command{Run: r}
+	}
+	return result
 }
 
 type Result struct {
-	Target     string
-	Passing    bool
-	WasPassing bool
-	First      bool
-	Duration   time.Duration
-	FinishedAt time.Time
+	Target        string
+	Passing       bool
+	WasPassing    bool
+	First         bool
+	Duration      time.Duration
+	FinishedAt    time.Time
+	Changed       string
+	FailedCommand string
+	RunCount      int
+	PassStreak    int
 }
 
 func New(
 	target string,
-	commands []string,
-	passCommands []string,
-	failCommands []string,
+	commands []*Command,
+	passCommands []*Command,
+	failCommands []*Command,
 	shell string,
 	dir string,
 	showOutput bool) *E {
 
 	if shell == "" {
-		shell = defaultShell
+		shell = DefaultShell
 	}
 
 	return &E{
@@ -73,17 +121,97 @@ func New(
 	}
 }
 
+func (e *E) Target() string {
+	return e.target
+}
+
+// Inherit continues the run history of the other executor, such as one that
+// this executor replaces, so that counters, streaks, and pass or fail
+// transitions carry over.
+func (e *E) Inherit(other *E) {
+	other.mu.Lock()
+	defer other.mu.Unlock()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.first = other.first
+	e.passing = other.passing
+	e.runCount = other.runCount
+	e.passStreak = other.passStreak
+}
+
+// AddRule adds commands that run, after the executor's commands, only when
+// the changed file is selected by the selector. Rules run in the order that
+// they were added.
+func (e *E) AddRule(selector Selector, commands []*Command) {
+	e.rules = append(e.rules, &rule{
+		selector: selector,
+		commands: commands,
+	})
+}
+
+// UseGoPackages makes the executor determine which Go packages are affected
+// by the changed file, according to the gopackages mode, and pass them to
+// commands in the BACON_GO_PACKAGES environment variable, and in place of
+// {packages}. The imports of the module's packages are listed once, and kept
+// up to date as files change.
+func (e *E) UseGoPackages(mode string) {
+	e.goPackages = nil
+	if mode != "" {
+		e.goPackages = gopackages.New(e.dir, mode)
+	}
+}
+
+// UseEnv sets environment variables, in KEY=VALUE form, for all commands.
+// They override the environment of bacon, and are overridden by those of
+// individual commands.
+func (e *E) UseEnv(env []string) {
+	e.env = env
+}
+
+// commandsFor returns the executor's commands, followed by the commands of
+// each rule that selects the changed file.
+func (e *E) commandsFor(changed string) []*Command {
+	if changed == "" || len(e.rules) == 0 {
+		return e.commands
+	}
+
+	cmds := e.commands[:len(e.commands):len(e.commands)]
+	for _, r := range e.rules {
+		sel, err := r.selector.Selected(changed)
+		if err != nil {
+			_, _ = fmt.Fprintln(e.err, err.Error())
+			continue
+		}
+		if sel {
+			cmds = append(cmds, r.commands...)
+		}
+	}
+	return cmds
+}
+
 func (e *E) RunCommands(
 	changed string,
 	args []string) *Result {
 
 	start := time.Now()
 	pass := true
+	var failedCmd string
+
+	var pkgs []string
+	if e.goPackages != nil {
+		var err error
+		pkgs, err = e.goPackages.Affected(changed)
+		if err != nil {
+			_, _ = fmt.Fprintln(e.err, err.Error())
+		}
+	}
 
-	for _, cmd := range e.commands {
-		err := e.runCommand(changed, cmd, args)
+	for _, cmd := range e.commandsFor(changed) {
+		err := e.runCommand(changed, pkgs, cmd, args)
 		if err != nil {
 			pass = false
+			failedCmd = cmd.Run
 			break
 		}
 	}
@@ -93,7 +221,7 @@ func (e *E) RunCommands(
 		passFailCommands = e.failCommands
 	}
 	for _, cmd := range passFailCommands {
-		err := e.runCommand(changed, cmd, args)
+		err := e.runCommand(changed, pkgs, cmd, args)
 		if err != nil {
 			_, _ = os.Stderr.Write([]byte(err.Error()))
 		}
@@ -109,24 +237,45 @@ func (e *E) RunCommands(
 	e.first = false
 	wasPassing := e.passing
 	e.passing = pass
+	e.runCount++
+	if pass {
+		e.passStreak++
+	} else {
+		e.passStreak = 0
+	}
 
 	return &Result{
-		Target:     e.target,
-		Passing:    pass,
-		WasPassing: wasPassing,
-		First:      first,
-		Duration:   duration,
-		FinishedAt: end,
+		Target:        e.target,
+		Passing:       pass,
+		WasPassing:    wasPassing,
+		First:         first,
+		Duration:      duration,
+		FinishedAt:    end,
+		Changed:       changed,
+		FailedCommand: failedCmd,
+		RunCount:      e.runCount,
+		PassStreak:    e.passStreak,
 	}
 }
 
-func (e *E) makeCommand(changed string, cmdStr string, args []string) *exec.Cmd {
+func (e *E) makeCommand(changed string, pkgs []string, c *Command, args []string) *exec.Cmd {
+	cmdStr := c.Run
+	if e.goPackages != nil {
+		cmdStr = strings.Replace(cmdStr, packagesPlaceholder, packagesArg(pkgs), -1)
+	}
+
 	cmd := exec.Command(e.shell, "-c", cmdStr)
 
+	// Later entries take precedence
 	cmd.Env = os.Environ()
+	cmd.Env = append(cmd.Env, e.env...)
+	cmd.Env = append(cmd.Env, c.Env...)
 	if changed != "" {
 		cmd.Env = append(cmd.Env, "BACON_CHANGED="+changed)
 	}
+	if e.goPackages != nil {
+		cmd.Env = append(cmd.Env, "BACON_GO_PACKAGES="+packagesEnv(pkgs))
+	}
 
 	if e.dir != "" {
 		cmd.Dir = e.dir
@@ -135,13 +284,37 @@ func (e *E) makeCommand(changed string, cmdStr string, args []string) *exec.Cmd
 	return cmd
 }
 
+// packagesArg formats the packages as shell arguments, or as all packages
+// when it's not known which are affected.
+func packagesArg(pkgs []string) string {
+	if len(pkgs) == 0 {
+		return allPackages
+	}
+
+	quoted := make([]string, len(pkgs))
+	for i, p := range pkgs {
+		quoted[i] = util.ShellQuote(p)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// packagesEnv formats the packages as the value of BACON_GO_PACKAGES, which,
+// like packagesArg, is all packages when it's not known which are affected.
+func packagesEnv(pkgs []string) string {
+	if len(pkgs) == 0 {
+		return allPackages
+	}
+	return strings.Join(pkgs, " ")
+}
+
 func (e *E) runCommand(
 	changed string,
-	str string,
+	pkgs []string,
+	c *Command,
 	args []string,
 ) error {
-	args = append([]string{str}, args...)
-	cmd := e.makeCommand(changed, str, args)
+	args = append([]string{c.Run}, args...)
+	cmd := e.makeCommand(changed, pkgs, c, args)
 
 	var outBuf bytes.Buffer
 	var errBuf bytes.Buffer
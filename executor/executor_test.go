@@ -2,8 +2,9 @@ package executor
 
 import (
 	"bytes"
+	"os"
+	"strings"
 	"testing"
-	"time"
 )
 
 func TestE_RunCommands(t *testing.T) {
@@ -24,7 +25,7 @@ func TestE_RunCommands(t *testing.T) {
 			[]string{},
 			false,
 			[]string{},
-			&Result{"a", true, true, true, 0, time.Time{}},
+			&Result{Target: "a", Passing: true, WasPassing: true, First: true},
 			"",
 		},
 		{ // Show output when enabled
@@ -33,7 +34,7 @@ func TestE_RunCommands(t *testing.T) {
 			[]string{},
 			true,
 			[]string{},
-			&Result{"a", true, true, true, 0, time.Time{}},
+			&Result{Target: "a", Passing: true, WasPassing: true, First: true},
 			"foo\n",
 		},
 		{ // Multiple commands
@@ -42,7 +43,7 @@ func TestE_RunCommands(t *testing.T) {
 			[]string{},
 			true,
 			[]string{},
-			&Result{"a", true, true, true, 0, time.Time{}},
+			&Result{Target: "a", Passing: true, WasPassing: true, First: true},
 			"foo\nbar\n",
 		},
 		{ // Show error when output disabled
@@ -51,7 +52,7 @@ func TestE_RunCommands(t *testing.T) {
 			[]string{},
 			false,
 			[]string{},
-			&Result{"a", true, true, true, 0, time.Time{}},
+			&Result{Target: "a", Passing: true, WasPassing: true, First: true},
 			"foo\n",
 		},
 		{ // Show error when output enabled
@@ -60,7 +61,7 @@ func TestE_RunCommands(t *testing.T) {
 			[]string{},
 			true,
 			[]string{},
-			&Result{"a", true, true, true, 0, time.Time{}},
+			&Result{Target: "a", Passing: true, WasPassing: true, First: true},
 			"foo\n",
 		},
 		{ // Show output and error when output enabled
@@ -69,7 +70,7 @@ func TestE_RunCommands(t *testing.T) {
 			[]string{},
 			true,
 			[]string{},
-			&Result{"a", true, true, true, 0, time.Time{}},
+			&Result{Target: "a", Passing: true, WasPassing: true, First: true},
 			"foo\nbar\n",
 		},
 		{ // Output comes before error
@@ -78,7 +79,7 @@ func TestE_RunCommands(t *testing.T) {
 			[]string{},
 			true,
 			[]string{},
-			&Result{"a", true, true, true, 0, time.Time{}},
+			&Result{Target: "a", Passing: true, WasPassing: true, First: true},
 			"foo\nbaz\nbar\n",
 		},
 
@@ -89,7 +90,7 @@ func TestE_RunCommands(t *testing.T) {
 			[]string{},
 			false,
 			[]string{},
-			&Result{"a", false, true, true, 0, time.Time{}},
+			&Result{Target: "a", Passing: false, WasPassing: true, First: true},
 			"",
 		},
 		{ // Show output as error on failures
@@ -98,7 +99,7 @@ func TestE_RunCommands(t *testing.T) {
 			[]string{},
 			false,
 			[]string{},
-			&Result{"a", false, true, true, 0, time.Time{}},
+			&Result{Target: "a", Passing: false, WasPassing: true, First: true},
 			"foo\n",
 		},
 		{ // Show error as error on failures
@@ -107,7 +108,7 @@ func TestE_RunCommands(t *testing.T) {
 			[]string{},
 			false,
 			[]string{},
-			&Result{"a", false, true, true, 0, time.Time{}},
+			&Result{Target: "a", Passing: false, WasPassing: true, First: true},
 			"foo\n",
 		},
 		{ // Show output and error as error on failures
@@ -116,7 +117,7 @@ func TestE_RunCommands(t *testing.T) {
 			[]string{},
 			false,
 			[]string{},
-			&Result{"a", false, true, true, 0, time.Time{}},
+			&Result{Target: "a", Passing: false, WasPassing: true, First: true},
 			"foo\nbar\n",
 		},
 
@@ -127,7 +128,7 @@ func TestE_RunCommands(t *testing.T) {
 			[]string{"echo no"},
 			true,
 			[]string{},
-			&Result{"a", true, true, true, 0, time.Time{}},
+			&Result{Target: "a", Passing: true, WasPassing: true, First: true},
 			"foo\nyes\n",
 		},
 		{ // Run fail commands on failure
@@ -136,7 +137,7 @@ func TestE_RunCommands(t *testing.T) {
 			[]string{"echo no"},
 			true,
 			[]string{},
-			&Result{"a", false, true, true, 0, time.Time{}},
+			&Result{Target: "a", Passing: false, WasPassing: true, First: true},
 			"foo\nno\n",
 		},
 		{ // Multiple pass commands
@@ -145,7 +146,7 @@ func TestE_RunCommands(t *testing.T) {
 			[]string{"echo no"},
 			true,
 			[]string{},
-			&Result{"a", true, true, true, 0, time.Time{}},
+			&Result{Target: "a", Passing: true, WasPassing: true, First: true},
 			"foo\nyes\nagain\n",
 		},
 		{ // Multiple fail commands
@@ -154,7 +155,7 @@ func TestE_RunCommands(t *testing.T) {
 			[]string{"echo no", "echo again"},
 			true,
 			[]string{},
-			&Result{"a", false, true, true, 0, time.Time{}},
+			&Result{Target: "a", Passing: false, WasPassing: true, First: true},
 			"foo\nno\nagain\n",
 		},
 		{ // Pass command failure doesn't influence overall result
@@ -163,7 +164,7 @@ func TestE_RunCommands(t *testing.T) {
 			[]string{"echo no"},
 			true,
 			[]string{},
-			&Result{"a", true, true, true, 0, time.Time{}},
+			&Result{Target: "a", Passing: true, WasPassing: true, First: true},
 			"foo\nyes\n",
 		},
 		{ // Fail command failure doesn't.. uh.. magically make the overall result success?
@@ -172,7 +173,7 @@ func TestE_RunCommands(t *testing.T) {
 			[]string{"echo no; exit 1"},
 			true,
 			[]string{},
-			&Result{"a", false, true, true, 0, time.Time{}},
+			&Result{Target: "a", Passing: false, WasPassing: true, First: true},
 			"foo\nno\n",
 		},
 		{ // Pass command doesn't output when output disabled
@@ -181,7 +182,7 @@ func TestE_RunCommands(t *testing.T) {
 			[]string{"echo no"},
 			false,
 			[]string{},
-			&Result{"a", true, true, true, 0, time.Time{}},
+			&Result{Target: "a", Passing: true, WasPassing: true, First: true},
 			"",
 		},
 		{ // Pass command errors output when output disabled
@@ -190,7 +191,7 @@ func TestE_RunCommands(t *testing.T) {
 			[]string{"echo no"},
 			false,
 			[]string{},
-			&Result{"a", true, true, true, 0, time.Time{}},
+			&Result{Target: "a", Passing: true, WasPassing: true, First: true},
 			"yes\n",
 		},
 		{ // Fail command doesn't output when output disabled
@@ -199,7 +200,7 @@ func TestE_RunCommands(t *testing.T) {
 			[]string{"echo no"},
 			false,
 			[]string{},
-			&Result{"a", false, true, true, 0, time.Time{}},
+			&Result{Target: "a", Passing: false, WasPassing: true, First: true},
 			"foo\n",
 		},
 		{ // Fail command shows error when output disabled
@@ -208,7 +209,7 @@ func TestE_RunCommands(t *testing.T) {
 			[]string{"echo no 1>&2"},
 			false,
 			[]string{},
-			&Result{"a", false, true, true, 0, time.Time{}},
+			&Result{Target: "a", Passing: false, WasPassing: true, First: true},
 			"foo\nno\n",
 		},
 	}
@@ -218,9 +219,9 @@ func TestE_RunCommands(t *testing.T) {
 
 		e := New(
 			"a",
-			test.commands,
-			test.passCommands,
-			test.failCommands,
+			Commands(test.commands),
+			Commands(test.passCommands),
+			Commands(test.failCommands),
 			"",
 			"",
 			test.showOutput)
@@ -252,3 +253,127 @@ func resultsEqual(actual *Result, expected *Result) bool {
 	// Ignore times and durations
 	return true
 }
+
+func TestE_RunCommands_Counters(t *testing.T) {
+	var outBuf bytes.Buffer
+
+	pass := New("a", Commands([]string{"true"}), nil, nil, "", "", false)
+	pass.out = &outBuf
+	pass.err = &outBuf
+
+	r := pass.RunCommands("foo", nil)
+	if r.RunCount != 1 || r.PassStreak != 1 || r.FailedCommand != "" || r.Changed != "foo" {
+		t.Errorf("unexpected first result: %#v", r)
+	}
+	r = pass.RunCommands("", nil)
+	if r.RunCount != 2 || r.PassStreak != 2 || r.Changed != "" {
+		t.Errorf("unexpected second result: %#v", r)
+	}
+
+	fail := New("a", Commands([]string{"true", "false", "echo never"}), nil, nil, "", "", false)
+	fail.out = &outBuf
+	fail.err = &outBuf
+
+	r = fail.RunCommands("", nil)
+	if r.RunCount != 1 || r.PassStreak != 0 || r.FailedCommand != "false" {
+		t.Errorf("unexpected failing result: %#v", r)
+	}
+}
+
+func TestE_Inherit(t *testing.T) {
+	var outBuf bytes.Buffer
+
+	old := New("a", Commands([]string{"true"}), nil, nil, "", "", false)
+	old.out = &outBuf
+	old.err = &outBuf
+	old.RunCommands("", nil)
+	old.RunCommands("", nil)
+
+	e := New("a", Commands([]string{"false"}), nil, nil, "", "", false)
+	e.out = &outBuf
+	e.err = &outBuf
+	e.Inherit(old)
+
+	r := e.RunCommands("", nil)
+	if r.RunCount != 3 || r.PassStreak != 0 || r.First || !r.WasPassing || r.Passing {
+		t.Errorf("unexpected result: %#v", r)
+	}
+}
+
+type suffixSelector string
+
+func (s suffixSelector) Selected(path string) (bool, error) {
+	return strings.HasSuffix(path, string(s)), nil
+}
+
+func TestE_AddRule(t *testing.T) {
+	var tests = []struct {
+		changed string
+		exp     string
+	}{
+		{"", "all\n"},
+		{"foo.txt", "all\n"},
+		{"foo.proto", "all\nproto\n"},
+		{"foo_test.go", "all\ntest\ngo\n"},
+	}
+
+	for i, test := range tests {
+		var outBuf bytes.Buffer
+
+		e := New("a", Commands([]string{"echo all"}), nil, nil, "", "", true)
+		e.out = &outBuf
+		e.err = &outBuf
+		e.AddRule(suffixSelector(".proto"), Commands([]string{"echo proto"}))
+		e.AddRule(suffixSelector("_test.go"), Commands([]string{"echo test"}))
+		e.AddRule(suffixSelector(".go"), Commands([]string{"echo go"}))
+
+		e.RunCommands(test.changed, nil)
+		outStr := outBuf.String()
+		if outStr != test.exp {
+			t.Errorf("%d. unexpected output:\nexpected=%#v,\nactual=%#v\n", i, test.exp, outStr)
+		}
+	}
+}
+
+func TestE_UseGoPackages(t *testing.T) {
+	var outBuf bytes.Buffer
+
+	e := New("a", Commands([]string{"echo {packages} \"[$BACON_GO_PACKAGES]\""}), nil, nil, "", "", true)
+	e.out = &outBuf
+	e.err = &outBuf
+	e.UseGoPackages("changed")
+
+	e.RunCommands("", nil)
+
+	exp := "./... [./...]\n"
+	if outBuf.String() != exp {
+		t.Errorf("unexpected output:\nexpected=%#v,\nactual=%#v\n", exp, outBuf.String())
+	}
+}
+
+func TestE_UseEnv(t *testing.T) {
+	var outBuf bytes.Buffer
+
+	_ = os.Setenv("BACON_TEST_A", "os")
+	_ = os.Setenv("BACON_TEST_B", "os")
+	_ = os.Setenv("BACON_TEST_C", "os")
+	defer os.Unsetenv("BACON_TEST_A")
+	defer os.Unsetenv("BACON_TEST_B")
+	defer os.Unsetenv("BACON_TEST_C")
+
+	cmds := []*Command{
+		{Run: "echo $BACON_TEST_A $BACON_TEST_B $BACON_TEST_C", Env: []string{"BACON_TEST_C=cmd"}},
+		{Run: "echo $BACON_TEST_C"},
+	}
+	e := New("a", cmds, nil, nil, "", "", true)
+	e.out = &outBuf
+	e.err = &outBuf
+	e.UseEnv([]string{"BACON_TEST_B=target", "BACON_TEST_C=target"})
+
+	e.RunCommands("", nil)
+
+	exp := "os target cmd\ntarget\n"
+	if outBuf.String() != exp {
+		t.Errorf("unexpected output:\nexpected=%#v,\nactual=%#v\n", exp, outBuf.String())
+	}
+}
@@ -1,6 +1,7 @@
 package expander
 
 import (
+	"fmt"
 	"github.com/bmatcuk/doublestar"
 	"github.com/troykinsella/bacon/util"
 	"os"
@@ -9,9 +10,40 @@ import (
 	"strings"
 )
 
+const (
+	// defaultWatch is the watch glob when none are given
+	defaultWatch = "**/*"
+	// defaultExclude is the exclude glob when none are given
+	defaultExclude = "**/.*"
+)
+
+// E expands an ordered list of watch and exclude rules into the set of files
+// and directories to watch. Rules are evaluated in order, watch rules first,
+// and the last rule that matches a path decides whether it is selected. A rule
+// prefixed with "!" is negated: a negated watch rule excludes, and a negated
+// exclude rule re-includes.
 type E struct {
-	includes []string
-	excludes []string
+	dir        string
+	rules      []*rule
+	ignore     *ignorer
+	follow     bool
+	ignoreCase bool
+}
+
+type rule struct {
+	source  string
+	pattern string
+	globs   []string
+	exclude bool
+
+	// ignoreFiles marks the position at which ignore files are consulted
+	ignoreFiles bool
+}
+
+// Decision describes whether a path is selected, and which rule decided it.
+type Decision struct {
+	Selected bool
+	Rule     string
 }
 
 func New(
@@ -19,22 +51,65 @@ func New(
 	includes []string,
 	excludes []string,
 ) *E {
-	includes = normalizeGlobs(dir, includes, "**/*", false)
-	excludes = normalizeGlobs(dir, excludes, "**/.*", true)
+	rules := newRules(dir, includes, defaultWatch, false)
+	rules = append(rules, &rule{ignoreFiles: true})
+	rules = append(rules, newRules(dir, excludes, defaultExclude, true)...)
 
 	return &E{
-		includes: includes,
-		excludes: excludes,
+		dir:   rootDir(dir),
+		rules: rules,
+	}
+}
+
+// UseIgnoreFiles excludes paths that are ignored by .gitignore, .ignore, and
+// .baconignore files found in the expander's directory, its subdirectories,
+// and its parent directories up to the root of its repository, following
+// gitignore semantics. Ignore files are consulted after watch rules, so
+// exclude rules can re-include ignored paths. Edited ignore files take effect
+// on the next call to BaseDirs, List, Selected, or Explain.
+func (e *E) UseIgnoreFiles() {
+	e.ignore = newIgnorer(e.dir, DefaultIgnoreFiles)
+}
+
+// refreshIgnores makes the ignore files be checked for changes before they
+// are next consulted.
+func (e *E) refreshIgnores() {
+	if e.ignore != nil {
+		e.ignore.refresh()
 	}
 }
 
+// FollowSymlinks makes the expander descend into symlinked directories, and
+// list symlinked files, while matching globs against the paths as reached
+// through the symlinks. Symlink cycles are visited once.
+func (e *E) FollowSymlinks() {
+	e.follow = true
+}
+
+// IgnoreCase makes glob matching case-insensitive, for case-insensitive
+// file systems, or projects with inconsistently cased file names.
+func (e *E) IgnoreCase() {
+	e.ignoreCase = true
+}
+
+// FollowsSymlinks answers whether FollowSymlinks has been called.
+func (e *E) FollowsSymlinks() bool {
+	return e.follow
+}
+
 func (e *E) BaseDirs() ([]string, error) {
+	e.refreshIgnores()
 	set := make(map[string]bool)
 
-	for _, inc := range e.includes {
-		err := baseDir(inc, e.excludes, set)
-		if err != nil {
-			return nil, err
+	for _, r := range e.rules {
+		if r.exclude || r.ignoreFiles {
+			continue
+		}
+		for _, g := range r.globs {
+			err := e.baseDir(g, set)
+			if err != nil {
+				return nil, err
+			}
 		}
 	}
 
@@ -46,35 +121,90 @@ func (e *E) BaseDirs() ([]string, error) {
 	return result, nil
 }
 
-func baseDir(inc string, excludes []string, resultSet map[string]bool) error {
-	ms, err := doublestar.Glob(inc)
-	if err != nil {
-		return err
+// baseDir walks the tree below the literal leading path of the include glob,
+// adding each matching directory, or the directory of each matching file, to
+// the result set. Directories in which nothing can be selected are pruned
+// from the walk rather than filtered afterwards, so that large excluded trees
+// are never visited.
+func (e *E) baseDir(inc string, resultSet map[string]bool) error {
+	base := globBase(inc)
+	if e.ignoreCase {
+		base = existingAncestor(base)
 	}
 
-	for _, m := range ms {
-		d, err := util.IsDir(m)
-		if err != nil {
-			continue
-		}
-		if !d {
-			m = filepath.Dir(m)
+	// Without "**", the glob can't match anything deeper than its segments
+	maxDepth := -1
+	if !strings.Contains(inc, "**") {
+		maxDepth = depth(inc) - depth(base)
+	}
+
+	return walk(base, e.follow, func(p string, isDir bool) error {
+		dir := p
+		if isDir {
+			pruned, err := e.dirPruned(p)
+			if err != nil {
+				return err
+			}
+			if pruned {
+				return filepath.SkipDir
+			}
+		} else {
+			dir = filepath.Dir(p)
+			if p == base {
+				pruned, err := e.dirPruned(dir)
+				if err != nil || pruned {
+					return err
+				}
+			}
 		}
 
-		ex, err := matches(m, excludes)
+		m, err := e.matches(p, []string{inc})
 		if err != nil {
 			return err
 		}
+		if m {
+			resultSet[dir] = true
+		}
 
-		if !ex {
-			resultSet[m] = true
+		if isDir && maxDepth >= 0 && depth(p)-depth(base) >= maxDepth {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+}
+
+func depth(path string) int {
+	return strings.Count(path, string(filepath.Separator))
+}
+
+// dirPruned answers whether nothing in the directory can be selected, because
+// it is excluded and no later rule could re-include anything below it.
+func (e *E) dirPruned(dir string) (bool, error) {
+	i, d, err := e.decide(dir, true)
+	if err != nil {
+		return false, err
+	}
+	if i < 0 || d.Selected {
+		return false, nil
+	}
+
+	for _, r := range e.rules[i+1:] {
+		if r.exclude || r.ignoreFiles {
+			continue
+		}
+		for _, g := range r.globs {
+			if e.mayMatchBelow(g, dir) {
+				return false, nil
+			}
 		}
 	}
 
-	return nil
+	return true, nil
 }
 
-func (e *E) List() ([]string, error) {
+// Candidates lists the files in the base directories, whether or not they
+// are selected.
+func (e *E) Candidates() ([]string, error) {
 	var result []string
 
 	baseDirs, err := e.BaseDirs()
@@ -95,19 +225,16 @@ func (e *E) List() ([]string, error) {
 		}
 
 		for _, fi := range fis {
-			if fi.IsDir() {
-				continue
-			}
-
 			fp := filepath.Join(dirName, fi.Name())
-			s, err := e.Selected(fp)
-			if err != nil {
-				return nil, err
+			if fi.Mode()&os.ModeSymlink != 0 {
+				fi, err = os.Stat(fp)
+				if err != nil {
+					continue // dangling
+				}
 			}
-			if !s {
+			if fi.IsDir() {
 				continue
 			}
-
 			result = append(result, fp)
 		}
 	}
@@ -116,21 +243,102 @@ func (e *E) List() ([]string, error) {
 	return result, nil
 }
 
+func (e *E) List() ([]string, error) {
+	var result []string
+
+	candidates, err := e.Candidates()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fp := range candidates {
+		d, err := e.explain(fp)
+		if err != nil {
+			return nil, err
+		}
+		if !d.Selected {
+			continue
+		}
+
+		result = append(result, fp)
+	}
+
+	return result, nil
+}
+
 func (e *E) Selected(path string) (bool, error) {
-	path = ensureRooted(path)
-	return selected(path, e.includes, e.excludes)
+	d, err := e.Explain(path)
+	if err != nil {
+		return false, err
+	}
+	return d.Selected, nil
 }
 
-func (e *E) pathSelected(path string) bool {
-	sel, err := selected(path, e.includes, e.excludes)
+// Explain decides whether the path is selected, and describes the rule that
+// decided it. A path that no rule matches is not selected.
+func (e *E) Explain(path string) (*Decision, error) {
+	e.refreshIgnores()
+	return e.explain(path)
+}
+
+func (e *E) explain(path string) (*Decision, error) {
+	path = ensureRooted(path)
+
+	isDir, err := util.IsDir(path)
 	if err != nil {
-		return false
+		isDir = false // deleted, most likely
 	}
-	return sel
+
+	_, d, err := e.decide(path, isDir)
+	return d, err
 }
 
-func matches(path string, includes []string) (bool, error) {
+// decide evaluates every rule against the path, and returns the index of the
+// last matching rule, or -1 if none match, along with the decision.
+func (e *E) decide(path string, isDir bool) (int, *Decision, error) {
+	index := -1
+	d := &Decision{}
+
+	for i, r := range e.rules {
+		if r.ignoreFiles {
+			if e.ignore == nil {
+				continue
+			}
+			ign, source, err := e.ignore.Ignored(path, isDir)
+			if err != nil {
+				return -1, nil, err
+			}
+			if ign {
+				index = i
+				d.Selected = false
+				d.Rule = "ignore file " + source
+			}
+			continue
+		}
+
+		m, err := e.matches(path, r.globs)
+		if err != nil {
+			return -1, nil, err
+		}
+		if m {
+			index = i
+			d.Selected = !r.exclude
+			d.Rule = fmt.Sprintf("%s %q", r.source, r.pattern)
+		}
+	}
+
+	return index, d, nil
+}
+
+func (e *E) matches(path string, includes []string) (bool, error) {
+	if e.ignoreCase {
+		path = strings.ToLower(path)
+	}
+
 	for _, i := range includes {
+		if e.ignoreCase {
+			i = strings.ToLower(i)
+		}
 		if path == i {
 			return true, nil
 		}
@@ -146,31 +354,40 @@ func matches(path string, includes []string) (bool, error) {
 	return false, nil
 }
 
-func selected(path string, includes []string, excludes []string) (bool, error) {
-	inc, err := matches(path, includes)
-	if err != nil {
-		return false, err
-	}
-	if !inc {
-		return false, nil
-	}
-
-	ex, err := matches(path, excludes)
-	if err != nil {
-		return false, err
-	}
-	if ex {
-		return false, nil
+// mayMatchBelow conservatively answers whether the glob could match a path
+// inside the directory, by comparing the directory with the glob's literal
+// leading path.
+func (e *E) mayMatchBelow(glob string, dir string) bool {
+	base := globBase(glob)
+	if e.ignoreCase {
+		base = strings.ToLower(base)
+		dir = strings.ToLower(dir)
 	}
 
-	return true, nil
+	sep := string(filepath.Separator)
+	return base == dir ||
+		strings.HasPrefix(base, dir+sep) ||
+		strings.HasPrefix(dir, base+sep) ||
+		base == sep
 }
 
-func normalizeGlobs(dir string, globs []string, defalt string, exclude bool) []string {
-	if globs == nil || len(globs) == 0 {
-		globs = []string{defalt}
+// globBase returns the leading path of the glob that contains no magic.
+func globBase(glob string) string {
+	sep := string(filepath.Separator)
+	parts := strings.Split(glob, sep)
+	for i, p := range parts {
+		if strings.ContainsAny(p, "*?[{\\") {
+			base := strings.Join(parts[:i], sep)
+			if base == "" {
+				return sep
+			}
+			return base
+		}
 	}
+	return glob
+}
 
+func rootDir(dir string) string {
 	cwd, err := os.Getwd()
 	if err != nil {
 		panic(err)
@@ -181,27 +398,116 @@ func normalizeGlobs(dir string, globs []string, defalt string, exclude bool) []s
 	} else if !filepath.IsAbs(dir) {
 		dir = filepath.Join(cwd, dir)
 	}
+	return dir
+}
 
-	for i, g := range globs {
-		if filepath.IsAbs(g) {
-			globs[i] = g
-		} else {
-			globs[i] = filepath.Join(dir, g)
+// newRules creates rules from a watch or exclude list, in order. When the list
+// has no non-negated globs, the default glob is prepended.
+func newRules(dir string, globs []string, defalt string, exclude bool) []*rule {
+	source := "watch"
+	if exclude {
+		source = "exclude"
+	}
+
+	var rules []*rule
+
+	hasPositive := false
+	for _, g := range globs {
+		if !strings.HasPrefix(g, "!") {
+			hasPositive = true
+			break
 		}
 	}
+	if !hasPositive {
+		rules = append(rules, &rule{
+			source:  "default " + source,
+			pattern: defalt,
+			globs:   normalizeGlob(dir, defalt, exclude),
+			exclude: exclude,
+		})
+	}
 
-	if exclude {
-		// Special case: If we exclude a directory, we must exclude all children
-		for _, g := range globs {
-			if !strings.HasSuffix(g, "/**") {
-				globs = append(globs, g+"/**")
-			}
+	for _, g := range globs {
+		negated := strings.HasPrefix(g, "!")
+		body := strings.TrimPrefix(g, "!")
+
+		// Special case: If we exclude a directory, we must exclude all
+		// children, and likewise for re-including an excluded directory.
+		expandDir := exclude || negated
+
+		rules = append(rules, &rule{
+			source:  source,
+			pattern: g,
+			globs:   normalizeGlob(dir, body, expandDir),
+			exclude: exclude != negated,
+		})
+	}
+
+	return rules
+}
+
+// NormalizeGlobs returns the watch and exclude globs as an expander for the
+// directory evaluates them: expanded, rooted on the directory, and with the
+// default globs prepended where they apply.
+func NormalizeGlobs(dir string, includes []string, excludes []string) ([]string, []string) {
+	return normalizeGlobs(dir, includes, defaultWatch, false), normalizeGlobs(dir, excludes, defaultExclude, true)
+}
+
+// normalizeGlobs roots each glob in the list on the directory, prepending the
+// default glob when the list has no non-negated globs. Negated globs retain
+// their "!" prefix.
+func normalizeGlobs(dir string, globs []string, defalt string, exclude bool) []string {
+	var result []string
+	for _, r := range newRules(dir, globs, defalt, exclude) {
+		prefix := ""
+		if r.exclude != exclude {
+			prefix = "!"
 		}
+		for _, g := range r.globs {
+			result = append(result, prefix+g)
+		}
+	}
+	return result
+}
+
+// normalizeGlob expands the glob, and roots the results on the directory.
+// When expandDir is true, a glob that may match a directory also matches
+// everything in it.
+func normalizeGlob(dir string, glob string, expandDir bool) []string {
+	dir = rootDir(dir)
+
+	if strings.HasPrefix(glob, typePrefix) {
+		expandDir = false
 	}
 
+	var globs []string
+	for _, g := range expandGlob(glob) {
+		if !filepath.IsAbs(g) {
+			g = filepath.Join(dir, g)
+		}
+
+		globs = append(globs, g)
+		if expandDir && !strings.HasSuffix(g, "/**") {
+			globs = append(globs, g+"/**")
+		}
+	}
 	return globs
 }
 
+// existingAncestor returns the path, or its nearest ancestor that exists.
+func existingAncestor(path string) string {
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
+
 func ensureRooted(path string) string {
 	if !filepath.IsAbs(path) {
 		cwd, err := os.Getwd()
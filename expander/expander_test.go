@@ -12,7 +12,11 @@ import (
 func prefix(s []string, p string) []string {
 	r := make([]string, len(s))
 	for i, e := range s {
-		r[i] = filepath.Join(p, e)
+		if strings.HasPrefix(e, "!") {
+			r[i] = "!" + filepath.Join(p, e[1:])
+		} else {
+			r[i] = filepath.Join(p, e)
+		}
 	}
 	return r
 }
@@ -78,6 +82,15 @@ func TestE_BaseDirs(t *testing.T) {
 		{[]string{"a/**"}, []string{"**/b"}, []string{"a", "a/c"}, ""},
 		{[]string{"a/**"}, []string{"**/b/**"}, []string{"a", "a/b", "a/c"}, ""},
 		{[]string{"a/**"}, []string{"**/b", "**/b/**"}, []string{"a", "a/c"}, ""},
+
+		{[]string{"a/**", "!a/b"}, []string{}, []string{"a", "a/c"}, ""},
+		{[]string{"a/**"}, []string{"a/b", "!a/b/d"}, []string{"a", "a/b", "a/c", "a/b/d"}, ""},
+		{[]string{"a/**", "!a/b", "a/b/d"}, []string{}, []string{"a", "a/b", "a/c", "a/b/d"}, ""},
+
+		{[]string{"a/{b,c}/*"}, []string{}, []string{"a/b", "a/b/d", "a/c"}, ""},
+		{[]string{"a/{b/d,c}/*"}, []string{}, []string{"a/b/d", "a/c"}, ""},
+		{[]string{"a/[bc]/*2"}, []string{}, []string{"a/b", "a/c"}, ""},
+		{[]string{"a/**/[!b]1"}, []string{}, []string{"a", "a/b/d", "a/c"}, ""},
 	}
 
 	for i, test := range tests {
@@ -135,6 +148,26 @@ func TestE_Selected(t *testing.T) {
 		{"foo/bar2", []string{"foo/**"}, []string{}, true, ""},
 		{"foo/bar/baz1", []string{"foo/*"}, []string{}, false, ""},
 		{"foo/bar/baz2", []string{"foo/**"}, []string{}, true, ""},
+
+		{"foo/bar", []string{"!foo/baz"}, []string{}, true, ""},
+		{"foo/baz", []string{"!foo/baz"}, []string{}, false, ""},
+		{"vendor/lib/a.go", []string{"**/*.go"}, []string{"vendor"}, false, ""},
+		{"vendor/lib/a.go", []string{"**/*.go"}, []string{"vendor", "!vendor/lib"}, true, ""},
+		{"vendor/lib/a.go", []string{"**/*.go"}, []string{"!vendor/lib", "vendor"}, false, ""},
+		{"vendor/lib/a.go", []string{"**/*.go", "!vendor/**", "vendor/lib/**"}, []string{}, true, ""},
+		{"vendor/other/a.go", []string{"**/*.go", "!vendor/**", "vendor/lib/**"}, []string{}, false, ""},
+
+		{"foo/bar.js", []string{"foo/{bar,baz}.js"}, []string{}, true, ""},
+		{"foo/qux.js", []string{"foo/{bar,baz}.js"}, []string{}, false, ""},
+		{"foo/x/y.js", []string{"foo/{a,x/y}.js"}, []string{}, true, ""},
+		{"foo/x", []string{"foo/[!y]"}, []string{}, true, ""},
+		{"foo/y", []string{"foo/[!y]"}, []string{}, false, ""},
+		{"foo/Bar", []string{"foo/[A-Z]*"}, []string{}, true, ""},
+		{"a/b/c.go", []string{"type:go"}, []string{}, true, ""},
+		{"a/go.mod", []string{"type:go"}, []string{}, true, ""},
+		{"a/b/c.rs", []string{"type:go"}, []string{}, false, ""},
+		{"a/b/c.py", []string{"type:python", "!type:go"}, []string{}, true, ""},
+		{"a/b/c.go", []string{"**"}, []string{"type:go"}, false, ""},
 	}
 
 	for i, test := range tests {
@@ -155,3 +188,31 @@ func TestE_Selected(t *testing.T) {
 		}
 	}
 }
+
+func TestE_Explain(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		path string
+		inc  []string
+		exc  []string
+		exp  Decision
+	}{
+		{"foo", []string{}, []string{}, Decision{true, `default watch "**/*"`}},
+		{".foo", []string{}, []string{}, Decision{false, `default exclude "**/.*"`}},
+		{"foo.go", []string{"*.go"}, []string{}, Decision{true, `watch "*.go"`}},
+		{"foo.c", []string{"*.go"}, []string{}, Decision{false, ""}},
+		{"a/foo.go", []string{"**/*.go"}, []string{"a", "!a/foo.go"}, Decision{true, `exclude "!a/foo.go"`}},
+		{"a/bar.go", []string{"**/*.go"}, []string{"a", "!a/foo.go"}, Decision{false, `exclude "a"`}},
+	}
+
+	for i, test := range tests {
+		e := New("", test.inc, test.exc)
+		r, err := e.Explain(test.path)
+		if err != nil {
+			t.Errorf("%d. \"%s\" unexpected error: %s\n", i, test.path, err.Error())
+		} else if *r != test.exp {
+			t.Errorf("%d. \"%s\" unexpected result:\nexpected=%#v,\nactual=%#v\n", i, test.path, test.exp, *r)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package expander
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+const typePrefix = "type:"
+
+// Types are the built-in file type presets, usable in place of a glob as
+// "type:<name>", such as "type:go".
+var Types = map[string][]string{
+	"go": {
+		"**/*.go",
+		"**/go.mod",
+		"**/go.sum",
+	},
+	"js": {
+		"**/*.{js,jsx,mjs,cjs}",
+		"**/package.json",
+	},
+	"ts": {
+		"**/*.{ts,tsx,mts,cts}",
+		"**/package.json",
+		"**/tsconfig*.json",
+	},
+	"python": {
+		"**/*.{py,pyi}",
+		"**/pyproject.toml",
+		"**/setup.{py,cfg}",
+		"**/requirements*.txt",
+	},
+	"rust": {
+		"**/*.rs",
+		"**/Cargo.{toml,lock}",
+	},
+}
+
+// TypeNames lists the names of the file type presets, sorted.
+func TypeNames() []string {
+	var names []string
+	for name := range Types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CheckGlob validates the syntax of a watch or exclude glob, which may be
+// negated with "!", or name a file type preset.
+func CheckGlob(glob string) error {
+	glob = strings.TrimPrefix(glob, "!")
+
+	if strings.HasPrefix(glob, typePrefix) {
+		name := strings.TrimPrefix(glob, typePrefix)
+		if _, ok := Types[name]; !ok {
+			return fmt.Errorf("unknown file type '%s' in glob '%s': expected one of %s",
+				name, glob, strings.Join(TypeNames(), ", "))
+		}
+		return nil
+	}
+
+	globs, err := expandBraces(glob)
+	if err != nil {
+		return fmt.Errorf("invalid glob '%s': %s", glob, err.Error())
+	}
+	for _, g := range globs {
+		if _, err := path.Match(translateClasses(g), ""); err != nil {
+			return fmt.Errorf("invalid glob '%s': %s", glob, err.Error())
+		}
+	}
+	return nil
+}
+
+// expandGlob expands a file type preset into its globs, then expands brace
+// alternations, such as "{a,b}", into separate globs, so that they are
+// handled the same way by matching and by walking. Invalid globs are
+// returned as they are, and fail when matched.
+func expandGlob(glob string) []string {
+	globs := []string{glob}
+	if strings.HasPrefix(glob, typePrefix) {
+		if preset, ok := Types[strings.TrimPrefix(glob, typePrefix)]; ok {
+			globs = preset
+		}
+	}
+
+	var result []string
+	for _, g := range globs {
+		expanded, err := expandBraces(g)
+		if err != nil {
+			expanded = []string{g}
+		}
+		for _, e := range expanded {
+			result = append(result, translateClasses(e))
+		}
+	}
+	return result
+}
+
+// expandBraces expands the first top-level brace alternation in the glob,
+// and recursively expands the results. Braces without a comma are literal.
+func expandBraces(glob string) ([]string, error) {
+	start := -1
+	nesting := 0
+	var commas []int
+
+	for i := 0; i < len(glob); i++ {
+		switch glob[i] {
+		case '\\':
+			i++
+		case '{':
+			if nesting == 0 {
+				start = i
+				commas = nil
+			}
+			nesting++
+		case ',':
+			if nesting == 1 {
+				commas = append(commas, i)
+			}
+		case '}':
+			if nesting == 0 {
+				continue // literal
+			}
+			nesting--
+			if nesting > 0 {
+				continue
+			}
+			if len(commas) == 0 {
+				// Literal braces; keep looking after them
+				rest, err := expandBraces(glob[i+1:])
+				if err != nil {
+					return nil, err
+				}
+				var result []string
+				for _, r := range rest {
+					result = append(result, glob[:i+1]+r)
+				}
+				return result, nil
+			}
+
+			prefix := glob[:start]
+			suffix := glob[i+1:]
+			bounds := append(append([]int{start}, commas...), i)
+
+			var result []string
+			for j := 0; j < len(bounds)-1; j++ {
+				alt := glob[bounds[j]+1 : bounds[j+1]]
+				expanded, err := expandBraces(prefix + alt + suffix)
+				if err != nil {
+					return nil, err
+				}
+				result = append(result, expanded...)
+			}
+			return result, nil
+		}
+	}
+
+	if nesting > 0 {
+		return nil, errors.New("unmatched '{'")
+	}
+	return []string{glob}, nil
+}
+
+// translateClasses rewrites negated character classes written as "[!...]",
+// as in shells and gitignore, into the "[^...]" form that doublestar expects.
+func translateClasses(glob string) string {
+	if !strings.Contains(glob, "[!") {
+		return glob
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		b.WriteByte(c)
+		if c == '\\' && i+1 < len(glob) {
+			i++
+			b.WriteByte(glob[i])
+		} else if c == '[' && i+1 < len(glob) && glob[i+1] == '!' {
+			b.WriteByte('^')
+			i++
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,100 @@
+package expander
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandBraces(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		glob string
+		exp  []string
+		err  string
+	}{
+		{"a", []string{"a"}, ""},
+		{"{a,b}", []string{"a", "b"}, ""},
+		{"x/{a,b}/y", []string{"x/a/y", "x/b/y"}, ""},
+		{"{a,b/c}/**", []string{"a/**", "b/c/**"}, ""},
+		{"{a,b}{1,2}", []string{"a1", "a2", "b1", "b2"}, ""},
+		{"{a,{b,c}}", []string{"a", "b", "c"}, ""},
+		{"{a}/{b,c}", []string{"{a}/b", "{a}/c"}, ""},
+		{"\\{a,b}", []string{"\\{a,b}"}, ""},
+		{"{a,b", nil, "unmatched '{'"},
+		{"a,b}", []string{"a,b}"}, ""},
+	}
+
+	for i, test := range tests {
+		r, err := expandBraces(test.glob)
+		if test.err == "" {
+			if err != nil {
+				t.Errorf("%d. \"%s\" unexpected error: %s\n", i, test.glob, err.Error())
+			} else if !reflect.DeepEqual(r, test.exp) {
+				t.Errorf("%d. \"%s\" unexpected result:\nexpected=%#v,\nactual=%#v\n", i, test.glob, test.exp, r)
+			}
+		} else {
+			if err == nil {
+				t.Errorf("%d. \"%s\" expected error:\nexpected=%s,\nactual=nil\n", i, test.glob, test.err)
+			} else if test.err != err.Error() {
+				t.Errorf("%d. \"%s\" unexpected error:\nexpected=%s,\nactual=%s\n", i, test.glob, test.err, err.Error())
+			}
+		}
+	}
+}
+
+func TestCheckGlob(t *testing.T) {
+	t.Parallel()
+
+	var tests = []struct {
+		glob string
+		err  string
+	}{
+		{"**/*.go", ""},
+		{"!**/*.go", ""},
+		{"type:go", ""},
+		{"!type:rust", ""},
+		{"[a-z]*", ""},
+		{"type:cobol", "unknown file type 'cobol' in glob 'type:cobol': expected one of go, js, python, rust, ts"},
+		{"{a,b", "invalid glob '{a,b': unmatched '{'"},
+		{"[a-", "invalid glob '[a-': syntax error in pattern"},
+	}
+
+	for i, test := range tests {
+		err := CheckGlob(test.glob)
+		if test.err == "" {
+			if err != nil {
+				t.Errorf("%d. \"%s\" unexpected error: %s\n", i, test.glob, err.Error())
+			}
+		} else {
+			if err == nil {
+				t.Errorf("%d. \"%s\" expected error:\nexpected=%s,\nactual=nil\n", i, test.glob, test.err)
+			} else if test.err != err.Error() {
+				t.Errorf("%d. \"%s\" unexpected error:\nexpected=%s,\nactual=%s\n", i, test.glob, test.err, err.Error())
+			}
+		}
+	}
+}
+
+func TestE_IgnoreCase(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"Src/Main.GO": "",
+		"src2/x.go":   "",
+	})
+
+	e := New(root, []string{"src/*.go"}, []string{})
+	e.IgnoreCase()
+
+	list, err := e.List()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	exp := prefix([]string{"Src/Main.GO"}, root)
+	if !pathsEqual(list, exp) {
+		t.Errorf("unexpected result:\nexpected=%s,\nactual=%s\n", exp, list)
+	}
+}
@@ -0,0 +1,292 @@
+package expander
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/bmatcuk/doublestar"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultIgnoreFiles are the names of the files from which ignore patterns
+// are loaded, in the order that their patterns are applied.
+var DefaultIgnoreFiles = []string{
+	".gitignore",
+	".ignore",
+	".baconignore",
+}
+
+// repoMarkers are the entries that mark the root of a repository, up to
+// which ignore files in parent directories apply.
+var repoMarkers = []string{
+	".git",
+	".hg",
+}
+
+// ignorer applies gitignore semantics to paths below a root directory,
+// loading ignore files from the root and each of its subdirectories, and
+// from its parent directories up to the root of the repository containing
+// it.
+type ignorer struct {
+	root  string
+	top   string
+	names []string
+
+	mu    *sync.Mutex
+	gen   int
+	cache map[string]*ignoreDir
+}
+
+// ignoreDir holds the rules of the ignore files of a directory, along with
+// the state of the files when they were read. The state is checked again
+// once per generation, so that edited ignore files are read again.
+type ignoreDir struct {
+	rules  []ignoreRule
+	states []ignoreFileState
+	gen    int
+}
+
+type ignoreFileState struct {
+	exists  bool
+	modTime time.Time
+	size    int64
+}
+
+type ignoreRule struct {
+	glob    string
+	negate  bool
+	dirOnly bool
+	source  string
+}
+
+func newIgnorer(root string, names []string) *ignorer {
+	return &ignorer{
+		root:  root,
+		top:   repoRoot(root),
+		names: names,
+		mu:    &sync.Mutex{},
+		cache: make(map[string]*ignoreDir),
+	}
+}
+
+// repoRoot returns the root of the repository containing the directory, or
+// the directory itself when it's not in a repository.
+func repoRoot(dir string) string {
+	for cur := dir; ; {
+		for _, m := range repoMarkers {
+			if _, err := os.Stat(filepath.Join(cur, m)); err == nil {
+				return cur
+			}
+		}
+		parent := filepath.Dir(cur)
+		if parent == cur {
+			return dir
+		}
+		cur = parent
+	}
+}
+
+// refresh starts a new generation, in which the ignore files are checked
+// for changes before their cached rules are used.
+func (ig *ignorer) refresh() {
+	ig.mu.Lock()
+	defer ig.mu.Unlock()
+	ig.gen++
+}
+
+// Ignored answers whether the path is ignored by the applicable ignore files,
+// along with a description of the pattern that ignored it. As with git, a
+// path inside an ignored directory is ignored regardless of any negated
+// patterns that match it. Only the root and the directories below it are
+// considered, so that a root inside an ignored directory is still watched.
+func (ig *ignorer) Ignored(path string, isDir bool) (bool, string, error) {
+	rel, err := filepath.Rel(ig.root, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return false, "", nil
+	}
+
+	parts := strings.Split(rel, string(filepath.Separator))
+	cur := ig.root
+	for i, part := range parts {
+		cur = filepath.Join(cur, part)
+		last := i == len(parts)-1
+
+		r, err := ig.match(cur, !last || isDir)
+		if err != nil {
+			return false, "", err
+		}
+		if r != nil && !r.negate {
+			return true, r.source, nil
+		}
+	}
+
+	return false, "", nil
+}
+
+// match evaluates the rules of every ignore file between the repository root
+// and the directory containing the path, and returns the last matching rule,
+// if any.
+func (ig *ignorer) match(path string, isDir bool) (*ignoreRule, error) {
+	var matched *ignoreRule
+
+	for _, dir := range ig.dirChain(filepath.Dir(path)) {
+		rules, err := ig.rules(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, r := range rules {
+			if r.dirOnly && !isDir {
+				continue
+			}
+			m, err := doublestar.PathMatch(r.glob, path)
+			if err != nil {
+				return nil, err
+			}
+			if m {
+				matched = &rules[i]
+			}
+		}
+	}
+
+	return matched, nil
+}
+
+// dirChain lists the directories from the repository root down to the given
+// directory.
+func (ig *ignorer) dirChain(dir string) []string {
+	var chain []string
+	for {
+		chain = append([]string{dir}, chain...)
+		if dir == ig.top {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return chain
+}
+
+func (ig *ignorer) rules(dir string) ([]ignoreRule, error) {
+	ig.mu.Lock()
+	defer ig.mu.Unlock()
+
+	d := ig.cache[dir]
+	if d != nil && d.gen == ig.gen {
+		return d.rules, nil
+	}
+
+	states, err := ig.states(dir)
+	if err != nil {
+		return nil, err
+	}
+	if d != nil && reflect.DeepEqual(states, d.states) {
+		d.gen = ig.gen
+		return d.rules, nil
+	}
+
+	var rules []ignoreRule
+	for _, name := range ig.names {
+		file := filepath.Join(dir, name)
+		b, err := os.ReadFile(file)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		rules = append(rules, parseIgnore(file, b)...)
+	}
+
+	ig.cache[dir] = &ignoreDir{
+		rules:  rules,
+		states: states,
+		gen:    ig.gen,
+	}
+	return rules, nil
+}
+
+// states returns the state of each ignore file of the directory.
+func (ig *ignorer) states(dir string) ([]ignoreFileState, error) {
+	states := make([]ignoreFileState, len(ig.names))
+	for i, name := range ig.names {
+		fi, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		states[i] = ignoreFileState{
+			exists:  true,
+			modTime: fi.ModTime(),
+			size:    fi.Size(),
+		}
+	}
+	return states, nil
+}
+
+// parseIgnore parses the contents of an ignore file into rules whose globs
+// are rooted at the file's directory.
+func parseIgnore(file string, b []byte) []ignoreRule {
+	var rules []ignoreRule
+	dir := filepath.Dir(file)
+	lineNum := 0
+
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		lineNum++
+		line := strings.TrimSuffix(s.Text(), "\r")
+
+		// Trailing spaces are ignored unless escaped
+		trimmed := strings.TrimRight(line, " ")
+		if strings.HasSuffix(trimmed, "\\") && len(trimmed) < len(line) {
+			trimmed += " "
+		}
+		line = trimmed
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		r := ignoreRule{
+			source: fmt.Sprintf("%s:%d %q", file, lineNum, line),
+		}
+		if strings.HasPrefix(line, "!") {
+			r.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+			line = line[1:]
+		}
+
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+
+		// A pattern containing a separator, other than a trailing one, is
+		// relative to the ignore file's directory. Otherwise, it matches at
+		// any depth.
+		if strings.Contains(line, "/") {
+			r.glob = filepath.Join(dir, strings.TrimPrefix(line, "/"))
+		} else {
+			r.glob = filepath.Join(dir, "**", line)
+		}
+		r.glob = translateClasses(r.glob)
+
+		rules = append(rules, r)
+	}
+
+	return rules
+}
@@ -0,0 +1,150 @@
+package expander
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFiles(t *testing.T, root string, files map[string]string) {
+	for name, content := range files {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestE_UseIgnoreFiles(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		".gitignore":  "# comment\n*.log\n!keep.log\n/build\ntmp/\ndocs/*.md\n",
+		".ignore":     "secret\n",
+		"a.go":        "",
+		"a.log":       "",
+		"keep.log":    "",
+		"secret":      "",
+		"build/x":     "",
+		"sub/build":   "",
+		"sub/tmp/y":   "",
+		"sub/tmp2":    "",
+		"docs/a.md":   "",
+		"docs/b/c.md": "",
+
+		"sub/.baconignore": "!a.log\nz.go\n",
+		"sub/a.log":        "",
+		"sub/z.go":         "",
+		"z.go":             "",
+
+		"out/.gitignore": "!x.log\n",
+		"out/x.log":      "",
+	})
+
+	var tests = []struct {
+		path string
+		exp  bool
+	}{
+		{"a.go", true},
+		{"a.log", false},
+		{"keep.log", true},
+		{"secret", false},
+		{"build/x", false},   // anchored directory
+		{"sub/build", true},  // anchored, so not matched below the root
+		{"sub/tmp/y", false}, // directory-only
+		{"sub/tmp2", true},
+		{"docs/a.md", false},
+		{"docs/b/c.md", true}, // single-level wildcard
+		{"sub/a.log", true},   // negated in a deeper ignore file
+		{"sub/z.go", false},
+		{"z.go", true}, // deeper ignore file doesn't apply above
+		{"out/x.log", true},
+	}
+
+	e := New(root, []string{"**"}, []string{})
+	e.UseIgnoreFiles()
+
+	for i, test := range tests {
+		r, err := e.Selected(filepath.Join(root, test.path))
+		if err != nil {
+			t.Errorf("%d. \"%s\" unexpected error: %s\n", i, test.path, err.Error())
+		} else if r != test.exp {
+			t.Errorf("%d. \"%s\" unexpected result:\nexpected=%t,\nactual=%t\n", i, test.path, test.exp, r)
+		}
+	}
+}
+
+func TestE_UseIgnoreFiles_Repo(t *testing.T) {
+	t.Parallel()
+
+	top := t.TempDir()
+	writeFiles(t, top, map[string]string{
+		".gitignore":          "above.txt\n",
+		"repo/.git/HEAD":      "",
+		"repo/.gitignore":     "*.log\n/sub/gen\n",
+		"repo/sub/.gitignore": "!keep.log\n",
+		"repo/sub/a.log":      "",
+		"repo/sub/keep.log":   "",
+		"repo/sub/above.txt":  "",
+		"repo/sub/gen/x.go":   "",
+		"repo/sub/b.go":       "",
+	})
+
+	var tests = []struct {
+		path string
+		exp  bool
+	}{
+		{"a.log", false},    // ignored by the repository root
+		{"keep.log", true},  // negated below it
+		{"above.txt", true}, // ignore files above the repository don't apply
+		{"gen/x.go", false}, // anchored at the repository root
+		{"b.go", true},
+	}
+
+	e := New(filepath.Join(top, "repo", "sub"), []string{"**"}, []string{})
+	e.UseIgnoreFiles()
+
+	for i, test := range tests {
+		r, err := e.Selected(filepath.Join(top, "repo", "sub", test.path))
+		if err != nil {
+			t.Errorf("%d. \"%s\" unexpected error: %s\n", i, test.path, err.Error())
+		} else if r != test.exp {
+			t.Errorf("%d. \"%s\" unexpected result:\nexpected=%t,\nactual=%t\n", i, test.path, test.exp, r)
+		}
+	}
+}
+
+func TestE_UseIgnoreFiles_Edited(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		".gitignore": "*.log\n",
+		"a.log":      "",
+	})
+
+	e := New(root, []string{"**"}, []string{})
+	e.UseIgnoreFiles()
+
+	path := filepath.Join(root, "a.log")
+	for i, content := range []string{"*.log\n", "*.tmp\n*.txt\n", ""} {
+		writeFiles(t, root, map[string]string{".gitignore": content})
+		if content == "" {
+			if err := os.Remove(filepath.Join(root, ".gitignore")); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		exp := content == "*.log\n"
+		r, err := e.Selected(path)
+		if err != nil {
+			t.Errorf("%d. unexpected error: %s\n", i, err.Error())
+		} else if r == exp {
+			t.Errorf("%d. unexpected result:\nexpected=%t,\nactual=%t\n", i, !exp, r)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package expander
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// walkFunc is called for each path visited by walk. Returning
+// filepath.SkipDir for a directory skips its contents.
+type walkFunc func(path string, isDir bool) error
+
+// walk calls fn for the root and every file and directory below it, in
+// lexical order, like filepath.WalkDir. When follow is true, symlinks are
+// followed, and each real directory is visited at most once, so that
+// symlink cycles terminate. Paths are reported as reached through the
+// symlinks, rather than as resolved. A root or entry that doesn't exist,
+// such as one deleted during the walk, is skipped, as are dangling symlinks
+// and directories that can't be read for lack of permission, but other
+// errors are returned.
+func walk(root string, follow bool, fn walkFunc) error {
+	stat := os.Lstat
+	if follow {
+		stat = os.Stat
+	}
+
+	fi, err := stat(root)
+	if err != nil {
+		// A parent of the root may be a file
+		if os.IsNotExist(err) || errors.Is(err, syscall.ENOTDIR) || errors.Is(err, fs.ErrPermission) {
+			return nil
+		}
+		return err
+	}
+
+	w := &walker{
+		follow:  follow,
+		fn:      fn,
+		readDir: os.ReadDir,
+		visited: make(map[string]bool),
+	}
+	return w.visit(root, fi.IsDir())
+}
+
+type walker struct {
+	follow  bool
+	fn      walkFunc
+	readDir func(path string) ([]os.DirEntry, error)
+	visited map[string]bool
+}
+
+func (w *walker) visit(path string, isDir bool) error {
+	err := w.fn(path, isDir)
+	if err == filepath.SkipDir && isDir {
+		return nil
+	}
+	if err != nil || !isDir {
+		return err
+	}
+
+	if w.follow {
+		real, err := filepath.EvalSymlinks(path)
+		if err != nil || w.visited[real] {
+			return nil
+		}
+		w.visited[real] = true
+	}
+
+	entries, err := w.readDir(path)
+	if err != nil {
+		// Like doublestar, skip directories that can't be read, such as
+		// those of root-owned volumes
+		if os.IsNotExist(err) || errors.Is(err, fs.ErrPermission) {
+			return nil
+		}
+		return err
+	}
+
+	for _, e := range entries {
+		p := filepath.Join(path, e.Name())
+
+		isDir := e.IsDir()
+		if w.follow && e.Type()&os.ModeSymlink != 0 {
+			fi, err := os.Stat(p)
+			if err != nil {
+				continue // dangling
+			}
+			isDir = fi.IsDir()
+		}
+
+		if err := w.visit(p, isDir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package expander
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestE_FollowSymlinks(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	shared := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"src/a.go": "",
+	})
+	writeFiles(t, shared, map[string]string{
+		"config/b.go": "",
+		"c.go":        "",
+	})
+
+	links := map[string]string{
+		"gen":      filepath.Join(shared, "config"), // directory outside the tree
+		"c.go":     filepath.Join(shared, "c.go"),   // file outside the tree
+		"src/loop": root,                            // cycle
+		"dangling": filepath.Join(shared, "nope"),
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var tests = []struct {
+		follow bool
+		exp    []string
+	}{
+		{false, []string{"c.go", "src/a.go"}},
+		{true, []string{"c.go", "gen/b.go", "src/a.go"}},
+	}
+
+	for i, test := range tests {
+		e := New(root, []string{"**/*.go"}, []string{})
+		if test.follow {
+			e.FollowSymlinks()
+		}
+
+		list, err := e.List()
+		if err != nil {
+			t.Errorf("%d. unexpected error: %s\n", i, err.Error())
+			continue
+		}
+
+		exp := prefix(test.exp, root)
+		if !pathsEqual(list, exp) {
+			t.Errorf("%d. unexpected result:\nexpected=%s,\nactual=%s\n", i, exp, list)
+		}
+	}
+}
+
+func TestE_List_Unreadable(t *testing.T) {
+	t.Parallel()
+
+	if os.Geteuid() == 0 {
+		t.Skip("permissions don't apply to root")
+	}
+
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"locked/a.go": "",
+		"open/b.go":   "",
+	})
+	locked := filepath.Join(root, "locked")
+	if err := os.Chmod(locked, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(locked, 0755)
+
+	e := New(root, []string{"**/*.go"}, []string{})
+	list, err := e.List()
+	if err != nil {
+		t.Errorf("unexpected error: %s\n", err.Error())
+	} else if exp := prefix([]string{"open/b.go"}, root); !pathsEqual(list, exp) {
+		t.Errorf("unexpected result:\nexpected=%s,\nactual=%s\n", exp, list)
+	}
+}
+
+func TestWalk_Unreadable(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"locked/a.go": "",
+		"open/b.go":   "",
+	})
+	locked := filepath.Join(root, "locked")
+
+	var tests = []struct {
+		err     error
+		expErr  string
+		expList []string
+	}{
+		{syscall.EACCES, "", []string{"open/b.go"}},
+		{syscall.EPERM, "", []string{"open/b.go"}},
+		{syscall.EIO, "open " + locked + ": input/output error", []string{}},
+	}
+
+	for i, test := range tests {
+		list := []string{}
+		w := &walker{
+			fn: func(p string, isDir bool) error {
+				if !isDir {
+					list = append(list, p)
+				}
+				return nil
+			},
+			readDir: func(p string) ([]os.DirEntry, error) {
+				if p == locked {
+					return nil, &fs.PathError{Op: "open", Path: p, Err: test.err}
+				}
+				return os.ReadDir(p)
+			},
+			visited: make(map[string]bool),
+		}
+
+		err := w.visit(root, true)
+		actualErr := ""
+		if err != nil {
+			actualErr = err.Error()
+		}
+		exp := prefix(test.expList, root)
+		if actualErr != test.expErr || !pathsEqual(list, exp) {
+			t.Errorf("%d. unexpected result:\nexpected=%s, %s,\nactual=%s, %s\n", i, test.expErr, exp, actualErr, list)
+		}
+	}
+}
+
+func TestE_BaseDirs_Missing(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	dirs, err := New(root, []string{"nope/**/*.go"}, []string{}).BaseDirs()
+	if err != nil {
+		t.Errorf("unexpected error: %s\n", err.Error())
+	} else if len(dirs) != 0 {
+		t.Errorf("unexpected result:\nexpected=[],\nactual=%s\n", dirs)
+	}
+}
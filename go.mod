@@ -6,10 +6,13 @@ toolchain go1.24.4
 
 require (
 	github.com/0xAX/notificator v0.0.0-20220220101646-ee9b8921e557
+	github.com/BurntSushi/toml v1.6.0
 	github.com/bmatcuk/doublestar v1.3.4
 	github.com/urfave/cli v1.22.17
+	golang.org/x/term v0.33.0
 	gopkg.in/fsnotify.v1 v1.4.7
 	gopkg.in/yaml.v2 v2.4.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -0,0 +1,159 @@
+package gopackages
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// ModeChanged selects the packages containing changed files.
+	ModeChanged = "changed"
+
+	// ModeDependents also selects the packages that depend on them,
+	// directly or transitively, including through tests.
+	ModeDependents = "dependents"
+)
+
+func CheckMode(mode string) error {
+	switch mode {
+	case "", ModeChanged, ModeDependents:
+		return nil
+	}
+	return fmt.Errorf("invalid Go packages mode '%s': expected %s or %s", mode, ModeChanged, ModeDependents)
+}
+
+// P finds the packages affected by changed files. In dependents mode, it
+// lists the imports of every package in the module once, and then keeps them
+// up to date by listing the imports of the package of each changed file.
+type P struct {
+	dir  string
+	mode string
+
+	mu      *sync.Mutex
+	imports map[string][]string
+}
+
+// New creates a P that runs "go list" in the directory, according to the
+// mode.
+func New(dir string, mode string) *P {
+	return &P{
+		dir:  dir,
+		mode: mode,
+		mu:   &sync.Mutex{},
+	}
+}
+
+// Affected returns the sorted import paths of the packages affected by the
+// changed file, according to the mode. It returns nil when the changed file
+// isn't a Go source file, in which case it's not known which packages are
+// affected.
+func (p *P) Affected(changed string) ([]string, error) {
+	if p.mode == "" || changed == "" || filepath.Ext(changed) != ".go" {
+		return nil, nil
+	}
+
+	out, err := goList(filepath.Dir(changed), "-e", "-f", importsFormat, ".")
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	pkg := fields[0]
+
+	if p.mode != ModeDependents {
+		return []string{pkg}, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.imports == nil {
+		imports, err := listImports(p.dir)
+		if err != nil {
+			return nil, err
+		}
+		p.imports = imports
+	}
+	// The change may have added or removed imports
+	p.imports[pkg] = fields[1:]
+
+	importers := make(map[string][]string)
+	for importer, imports := range p.imports {
+		for _, imp := range imports {
+			if imp != importer {
+				importers[imp] = append(importers[imp], importer)
+			}
+		}
+	}
+
+	affected := map[string]bool{pkg: true}
+	queue := []string{pkg}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, importer := range importers[cur] {
+			if !affected[importer] {
+				affected[importer] = true
+				queue = append(queue, importer)
+			}
+		}
+	}
+
+	var result []string
+	for a := range affected {
+		result = append(result, a)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// importsFormat makes "go list" print the import path of each package,
+// followed by the packages that it imports, including from tests.
+const importsFormat = "{{.ImportPath}}" +
+	"{{range .Imports}} {{.}}{{end}}" +
+	"{{range .TestImports}} {{.}}{{end}}" +
+	"{{range .XTestImports}} {{.}}{{end}}"
+
+// listImports maps each package matched by "./..." in the directory to the
+// packages that it imports.
+func listImports(dir string) (map[string][]string, error) {
+	out, err := goList(dir, "-e", "-f", importsFormat, "./...")
+	if err != nil {
+		return nil, err
+	}
+
+	imports := make(map[string][]string)
+
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		imports[fields[0]] = fields[1:]
+	}
+
+	return imports, nil
+}
+
+func goList(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("go", append([]string{"list"}, args...)...)
+	cmd.Dir = dir
+
+	var errBuf bytes.Buffer
+	cmd.Stderr = &errBuf
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list: %s: %s", err.Error(), strings.TrimSpace(errBuf.String()))
+	}
+	return out, nil
+}
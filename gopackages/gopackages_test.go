@@ -0,0 +1,109 @@
+package gopackages
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAffected(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"go.mod":       "module example.com/m\n\ngo 1.20\n",
+		"a/a.go":       "package a\n",
+		"b/b.go":       "package b\n\nimport _ \"example.com/m/a\"\n",
+		"c/c.go":       "package c\n",
+		"c/c_test.go":  "package c_test\n\nimport _ \"example.com/m/b\"\n",
+		"d/d.go":       "package d\n",
+		"d/README.txt": "",
+	}
+	for name, content := range files {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var tests = []struct {
+		changed string
+		mode    string
+		exp     []string
+	}{
+		{"", ModeChanged, nil},
+		{"a/a.go", "", nil},
+		{"d/README.txt", ModeChanged, nil},
+		{"a/a.go", ModeChanged, []string{"example.com/m/a"}},
+		{"a/a.go", ModeDependents, []string{"example.com/m/a", "example.com/m/b", "example.com/m/c"}},
+		{"b/b.go", ModeDependents, []string{"example.com/m/b", "example.com/m/c"}},
+		{"d/d.go", ModeDependents, []string{"example.com/m/d"}},
+	}
+
+	for i, test := range tests {
+		changed := test.changed
+		if changed != "" {
+			changed = filepath.Join(root, changed)
+		}
+
+		r, err := New(root, test.mode).Affected(changed)
+		if err != nil {
+			t.Errorf("%d. unexpected error: %s\n", i, err.Error())
+		} else if !reflect.DeepEqual(r, test.exp) {
+			t.Errorf("%d. unexpected result:\nexpected=%#v,\nactual=%#v\n", i, test.exp, r)
+		}
+	}
+}
+
+func TestP_Affected_Imports(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module example.com/m\n\ngo 1.20\n",
+		"a/a.go": "package a\n",
+		"b/b.go": "package b\n",
+	}
+	for name, content := range files {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := New(root, ModeDependents)
+	a := filepath.Join(root, "a", "a.go")
+	b := filepath.Join(root, "b", "b.go")
+
+	var tests = []struct {
+		write   string
+		content string
+		changed string
+		exp     []string
+	}{
+		{"", "", a, []string{"example.com/m/a"}},
+		// b now imports a, which is seen when b changes
+		{b, "package b\n\nimport _ \"example.com/m/a\"\n", b, []string{"example.com/m/b"}},
+		{"", "", a, []string{"example.com/m/a", "example.com/m/b"}},
+		{b, "package b\n", b, []string{"example.com/m/b"}},
+		{"", "", a, []string{"example.com/m/a"}},
+	}
+
+	for i, test := range tests {
+		if test.write != "" {
+			if err := os.WriteFile(test.write, []byte(test.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		r, err := p.Affected(test.changed)
+		if err != nil {
+			t.Errorf("%d. unexpected error: %s\n", i, err.Error())
+		} else if !reflect.DeepEqual(r, test.exp) {
+			t.Errorf("%d. unexpected result:\nexpected=%#v,\nactual=%#v\n", i, test.exp, r)
+		}
+	}
+}
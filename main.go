@@ -2,38 +2,81 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"github.com/troykinsella/bacon/baconfile"
 	"github.com/troykinsella/bacon/executor"
 	"github.com/troykinsella/bacon/expander"
+	"github.com/troykinsella/bacon/gopackages"
+	"github.com/troykinsella/bacon/reporter"
 	"github.com/troykinsella/bacon/util"
 	"github.com/troykinsella/bacon/watcher"
 	"github.com/urfave/cli"
+	"io"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
 )
 
 const (
 	AppName = "bacon"
 
-	baconFile        = "b"
-	baconFileLong    = baconFile + ", baconfile"
-	command          = "c"
-	commandLong      = command + ", cmd"
-	passCommand      = "p"
-	passCommandLong  = passCommand + ", pass"
-	failCommand      = "f"
-	failCommandLong  = failCommand + ", fail"
-	watch            = "w"
-	watchLong        = watch + ", watch"
-	watchExclude     = "e"
-	watchExcludeLong = watchExclude + ", exclude"
-	showOutput       = "o"
-	showOutputLong   = showOutput + ", show-output"
-	noNotify         = "no-notify"
-	shell            = "shell"
+	baconFile           = "b"
+	baconFileLong       = baconFile + ", baconfile"
+	command             = "c"
+	commandLong         = command + ", cmd"
+	passCommand         = "p"
+	passCommandLong     = passCommand + ", pass"
+	failCommand         = "f"
+	failCommandLong     = failCommand + ", fail"
+	watch               = "w"
+	watchLong           = watch + ", watch"
+	watchExclude        = "e"
+	watchExcludeLong    = watchExclude + ", exclude"
+	ignoreFiles         = "ignore-files"
+	followSymlinks      = "follow-symlinks"
+	ignoreCase          = "ignore-case"
+	explain             = "explain"
+	poll                = "poll"
+	pollInterval        = "poll-interval"
+	contentHash         = "content-hash"
+	goPackages          = "go-packages"
+	variable            = "var"
+	env                 = "env"
+	write               = "w"
+	writeLong           = write + ", write"
+	showOutput          = "o"
+	showOutputLong      = showOutput + ", show-output"
+	noNotify            = "no-notify"
+	noReload            = "no-reload"
+	projectTemplate     = "t"
+	projectTemplateLong = projectTemplate + ", template"
+	autoTemplate        = "auto"
+	yes                 = "y"
+	yesLong             = yes + ", yes"
+	force               = "force"
+	fileFormat          = "format"
+	jsonOut             = "json"
+	shell               = "shell"
+	statusFormat        = "status-format"
+	title               = "title"
+	tmux                = "tmux"
+	statusFile          = "status-file"
+	color               = "color"
+
+	colorAuto   = "auto"
+	colorAlways = "always"
+	colorNever  = "never"
+
+	tmuxStatusOption = "@bacon_status"
 
 	defaultTarget = "default"
 )
@@ -53,30 +96,69 @@ func newExecutor(c *cli.Context) (*executor.E, error) {
 	sh := c.String(shell)
 	showOut := c.Bool(showOutput)
 
+	goPkgs := c.String(goPackages)
+	if err := gopackages.CheckMode(goPkgs); err != nil {
+		return nil, cli.NewExitError(err.Error(), 1)
+	}
+
+	envs, err := parseEnv(c.StringSlice(env))
+	if err != nil {
+		return nil, cli.NewExitError(err.Error(), 1)
+	}
+
 	e := executor.New(
 		"",
-		cmds,
-		passCmds,
-		failCmds,
+		executor.Commands(cmds),
+		executor.Commands(passCmds),
+		executor.Commands(failCmds),
 		sh,
 		"",
 		showOut,
 	)
+	e.UseGoPackages(goPkgs)
+	e.UseEnv(envs)
 
 	return e, nil
 }
 
+// newExpander creates an expander from the watch flags.
+func newExpander(c *cli.Context) (*expander.E, error) {
+	includes := c.StringSlice(watch)
+	excludes := c.StringSlice(watchExclude)
+
+	for _, g := range append(includes[:len(includes):len(includes)], excludes...) {
+		if err := expander.CheckGlob(g); err != nil {
+			return nil, cli.NewExitError(err.Error(), 1)
+		}
+	}
+
+	exp := expander.New("", includes, excludes)
+	if c.Bool(ignoreFiles) {
+		exp.UseIgnoreFiles()
+	}
+	if c.Bool(followSymlinks) {
+		exp.FollowSymlinks()
+	}
+	if c.Bool(ignoreCase) {
+		exp.IgnoreCase()
+	}
+	return exp, nil
+}
+
 func newBacon(c *cli.Context) (*Bacon, error) {
-	exp := expander.New(
-		"",
-		c.StringSlice(watch),
-		c.StringSlice(watchExclude),
-	)
+	exp, err := newExpander(c)
+	if err != nil {
+		return nil, err
+	}
 
-	w, err := watcher.New(exp)
+	polls, interval := pollOptions(c)
+	w, err := newWatcher(exp, polls, interval)
 	if err != nil {
 		return nil, err
 	}
+	if c.Bool(contentHash) {
+		w.UseContentHashes()
+	}
 
 	exec, err := newExecutor(c)
 	if err != nil {
@@ -86,24 +168,118 @@ func newBacon(c *cli.Context) (*Bacon, error) {
 	showOut := c.Bool(showOutput)
 	noNotify := c.Bool(noNotify)
 
+	statusTpl, err := newStatusTemplate(c.String(statusFormat))
+	if err != nil {
+		return nil, err
+	}
+
+	reporters := newReporters(
+		c.Bool(title),
+		c.Bool(tmux),
+		c.String(statusFile),
+	)
+
+	colorOut, interactive, err := terminalMode(c.String(color))
+	if err != nil {
+		return nil, err
+	}
+
 	b := NewBacon(
 		w,
 		exec,
 		showOut,
 		!noNotify,
+		colorOut,
+		interactive,
+		statusTpl,
+		reporters,
 	)
 	return b, nil
 }
 
+// terminalMode determines whether to colour output, and whether the output
+// is interactive, meaning that the screen can be cleared and repainted.
+func terminalMode(mode string) (bool, bool, error) {
+	interactive := util.IsTerminal(os.Stdout)
+	useColor, err := colorMode(mode, interactive)
+	if err != nil {
+		return false, false, err
+	}
+	return useColor, interactive, nil
+}
+
+// colorMode determines whether to colour output. Colour is disabled in auto
+// mode when the output is not interactive, or the NO_COLOR environment
+// variable is set to a non-empty value.
+func colorMode(mode string, interactive bool) (bool, error) {
+	switch mode {
+	case "", colorAuto:
+		return interactive && os.Getenv("NO_COLOR") == "", nil
+	case colorAlways:
+		return true, nil
+	case colorNever:
+		return false, nil
+	}
+
+	return false, cli.NewExitError(fmt.Sprintf("invalid %s option: %s", color, mode), 1)
+}
+
+// newReporters creates the reporters of the options. The terminal title is
+// only set when stdout is a terminal, since the escape sequence would
+// otherwise end up in a pipe or a log file.
+func newReporters(setTitle bool, setTmux bool, file string) []reporter.R {
+	var reporters []reporter.R
+	if setTitle && util.IsTerminal(os.Stdout) {
+		reporters = append(reporters, reporter.NewTitle(AppName, os.Stdout))
+	}
+	if setTmux {
+		reporters = append(reporters, reporter.NewTmux(tmuxStatusOption))
+	}
+	if file != "" {
+		reporters = append(reporters, reporter.NewFile(file))
+	}
+	return reporters
+}
+
+// pollOptions returns whether the options ask to poll for changes, which
+// --poll-interval implies, and the polling interval.
+func pollOptions(c *cli.Context) (bool, time.Duration) {
+	return c.GlobalBool(poll) || c.GlobalIsSet(pollInterval), c.GlobalDuration(pollInterval)
+}
+
+// newWatcher creates a watcher that polls for changes, rather than being
+// notified of them, when poll is true.
+func newWatcher(exp *expander.E, poll bool, interval time.Duration) (*watcher.W, error) {
+	if poll {
+		return watcher.NewPolling(exp, interval)
+	}
+	return watcher.New(exp)
+}
+
+// newStatusTemplate parses the given status line format, returning a nil
+// template when the format is empty so that Bacon applies its default.
+func newStatusTemplate(format string) (*template.Template, error) {
+	if format == "" {
+		return nil, nil
+	}
+	return parseStatusFormat(format)
+}
+
 func newListCommand() *cli.Command {
 	return &cli.Command{
 		Name:  "list",
 		Usage: "Print effective files to watch given inclusion and exclusion globs and exit.",
 		Action: func(c *cli.Context) error {
-			includes := c.StringSlice(watch)
-			excludes := c.StringSlice(watchExclude)
+			e, err := newExpander(c)
+			if err != nil {
+				return err
+			}
+
+			if c.Bool(explain) {
+				polls, _ := pollOptions(c)
+				return explainList(e, polls)
+			}
 
-			e := expander.New("", includes, excludes)
 			list, err := e.List()
 			if err != nil {
 				return err
@@ -114,8 +290,51 @@ func newListCommand() *cli.Command {
 			}
 			return nil
 		},
-		Flags: newWatchFlags(),
+		Flags: append(newWatchFlags(),
+			cli.BoolFlag{
+				Name:  explain,
+				Usage: "Print every candidate file, marked + if selected or - if not, with the rule that decided it",
+			},
+		),
+	}
+}
+
+func explainList(e *expander.E, polls bool) error {
+	candidates, err := e.Candidates()
+	if err != nil {
+		return err
+	}
+
+	dirs, err := e.BaseDirs()
+	if err != nil {
+		return err
+	}
+
+	selected := 0
+	for _, f := range candidates {
+		d, err := e.Explain(f)
+		if err != nil {
+			return err
+		}
+
+		mark := "-"
+		if d.Selected {
+			mark = "+"
+			selected++
+		}
+		rule := d.Rule
+		if rule == "" {
+			rule = "no matching rule"
+		}
+		fmt.Printf("%s %s\t(%s)\n", mark, f, rule)
+	}
+
+	if polls {
+		fmt.Printf("%d of %d files selected, polling them for changes\n", selected, len(candidates))
+	} else {
+		fmt.Printf("%d of %d files selected, watching %d directories\n", selected, len(candidates), len(dirs))
 	}
+	return nil
 }
 
 func readString(in *bufio.Scanner, msg string, def string) string {
@@ -182,58 +401,59 @@ func readYesNo(in *bufio.Scanner, msg string, def bool) bool {
 func newInitCommand() *cli.Command {
 	return &cli.Command{
 		Name:  "init",
-		Usage: "Create a Baconfile by asking you questions.",
+		Usage: "Create a Baconfile from a project template, or by asking you questions.",
 		Action: func(c *cli.Context) error {
 			in := bufio.NewScanner(os.Stdin)
+			acceptDefaults := c.Bool(yes)
+
+			out := c.String(baconFile)
+			if out != "" {
+				if err := checkOverwrite(c, out); err != nil {
+					return err
+				}
+			}
 
-			targets := make(map[string]*baconfile.Target)
+			bf, err := initTemplate(c, in, acceptDefaults)
+			if err != nil {
+				return err
+			}
+			if bf == nil {
+				bf = readTargets(in)
+			}
 
-			for {
-				var tName string
+			format := baconfile.YAML
+			if c.IsSet(fileFormat) {
+				format, err = baconfile.ParseFormat(c.String(fileFormat))
+				if err != nil {
+					return cli.NewExitError(err.Error(), 1)
+				}
+			} else if !acceptDefaults {
 				for {
-					tName = readString(in, "Target name", "default")
-					if _, exists := targets[tName]; exists {
-						fmt.Println("Already entered this target name. Enter a different name.")
+					f, err := baconfile.ParseFormat(readString(in, "Baconfile format (yaml, toml, json)", string(baconfile.YAML)))
+					if err != nil {
+						fmt.Println(err.Error())
 						continue
 					}
+					format = f
 					break
 				}
-
-				dir := readString(in, "Working directory for patterns and commands", "")
-				watch := readStringSlice(in, "Watch file pattern list", "**/*", true)
-				cmd := readStringSlice(in, "Command list to run when files change", "", true)
-				pass := readStringSlice(in, "Execute list when the commands pass", "", false)
-				fail := readStringSlice(in, "Execute list when the commands fail", "", false)
-
-				t := &baconfile.Target{
-					Dir:     dir,
-					Watch:   watch,
-					Command: cmd,
-					Pass:    pass,
-					Fail:    fail,
-				}
-
-				targets[tName] = t
-
-				if !readYesNo(in, "Create another target?", false) {
-					break
-				}
-			}
-
-			bf := baconfile.B{
-				Targets: targets,
 			}
 
-			bytes, err := bf.Marshal()
+			bytes, err := bf.MarshalAs(format)
 			if err != nil {
 				return err
 			}
 
-			if readYesNo(in, "View Baconfile preview?", false) {
+			if !acceptDefaults && readYesNo(in, "View Baconfile preview?", false) {
 				fmt.Print(string(bytes))
 			}
 
-			out := readString(in, "Baconfile path", "Baconfile")
+			if out == "" {
+				out = "Baconfile" + format.Ext()
+				if !acceptDefaults {
+					out = readString(in, "Baconfile path", out)
+				}
+			}
 			if !filepath.IsAbs(out) {
 				cwd, err := os.Getwd()
 				if err != nil {
@@ -241,237 +461,1168 @@ func newInitCommand() *cli.Command {
 				}
 				out = filepath.Join(cwd, out)
 			}
+			if err := checkOverwrite(c, out); err != nil {
+				return err
+			}
 
-			if readYesNo(in, "Write Baconfile to "+out+"?", true) {
+			if acceptDefaults || readYesNo(in, "Write Baconfile to "+out+"?", true) {
 				err := os.WriteFile(out, bytes, 0644)
 				if err != nil {
 					return err
 				}
+				fmt.Printf("Wrote %s\n", out)
 			}
 
 			return nil
 		},
-	}
-}
-
-func newCommandCommand() *cli.Command {
-	return &cli.Command{
-		Name:  "command",
-		Usage: "Execute commands once and exit. Useful for testing a command chain.",
-		Action: func(c *cli.Context) error {
-			e, err := newExecutor(c)
-			if err != nil {
-				return err
-			}
-
-			r := e.RunCommands("", nil)
-			if !r.Passing {
-				return cli.NewExitError("", 1)
-			}
-
-			return nil
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  projectTemplateLong,
+				Usage: "Start from the `NAME`d project template: " + strings.Join(baconfile.TemplateNames(), ", ") + ", or auto to detect the type of project",
+			},
+			&cli.BoolFlag{
+				Name:  yesLong,
+				Usage: "Accept the defaults rather than asking questions, detecting the project template unless one is given",
+			},
+			&cli.StringFlag{
+				Name:  fileFormat,
+				Usage: "Write the Baconfile in the `FORMAT`: yaml, toml, or json (default: yaml)",
+			},
+			&cli.StringFlag{
+				Name:  baconFileLong,
+				Usage: "Write the Baconfile to `PATH` (default: Baconfile, with the format's extension)",
+			},
+			&cli.BoolFlag{
+				Name:  force,
+				Usage: "Overwrite an existing Baconfile",
+			},
 		},
-		Flags: newRunFlags(),
 	}
 }
 
-func loadBaconfile(path string, required bool) (*baconfile.B, error) {
-	exists, err := util.Exists(path)
-	if err != nil {
-		return nil, err
-	}
-	if !exists {
-		if required {
-			return nil, fmt.Errorf("baconfile not found: %s", path)
+// initTemplate returns the Baconfile of the project template given by the
+// --template option, or else of the detected type of project, if the user
+// accepts it. It returns nil when there's no template to start from.
+func initTemplate(c *cli.Context, in *bufio.Scanner, acceptDefaults bool) (*baconfile.B, error) {
+	name := c.String(projectTemplate)
+	if name == "" || name == autoTemplate {
+		detected, err := baconfile.DetectTemplate(".")
+		if err != nil {
+			return nil, err
 		}
-		return nil, nil
-	}
-
-	bytes, err := os.ReadFile(path)
-	if err != nil {
-		return nil, err
+		switch {
+		case detected == "" && (acceptDefaults || name == autoTemplate):
+			return nil, cli.NewExitError(fmt.Sprintf("no project type detected: use --template with one of %s", strings.Join(baconfile.TemplateNames(), ", ")), 1)
+		case detected == "":
+			return nil, nil
+		case name == "" && !acceptDefaults && !readYesNo(in, fmt.Sprintf("Detected a %s project. Start from its template?", detected), true):
+			return nil, nil
+		}
+		name = detected
 	}
 
-	bf, err := baconfile.Unmarshal(bytes)
+	bf, err := baconfile.Template(name)
 	if err != nil {
-		return nil, err
+		return nil, cli.NewExitError(err.Error(), 1)
 	}
-
 	return bf, nil
 }
 
-func findBaconfile(c *cli.Context) (*baconfile.B, error) {
-	searchOrder := []string{
-		c.String(baconFile),
-		"Baconfile",
-		"Baconfile.yml",
-		"Baconfile.yaml",
-		".Baconfile",
-		".Baconfile.yml",
-		".Baconfile.yaml",
-	}
+// readTargets creates a Baconfile by asking for its targets.
+func readTargets(in *bufio.Scanner) *baconfile.B {
+	targets := make(map[string]*baconfile.Target)
 
-	for i, path := range searchOrder {
-		if path == "" {
-			continue
+	for {
+		var tName string
+		for {
+			tName = readString(in, "Target name", "default")
+			if _, exists := targets[tName]; exists {
+				fmt.Println("Already entered this target name. Enter a different name.")
+				continue
+			}
+			break
 		}
 
-		bf, err := loadBaconfile(path, i == 0)
-		if err != nil {
-			return nil, err
+		dir := readString(in, "Working directory for patterns and commands", "")
+		watch := readStringSlice(in, "Watch file pattern list", "**/*", true)
+		cmd := readStringSlice(in, "Command list to run when files change", "", true)
+		pass := readStringSlice(in, "Execute list when the commands pass", "", false)
+		fail := readStringSlice(in, "Execute list when the commands fail", "", false)
+
+		t := &baconfile.Target{
+			Dir:     dir,
+			Watch:   watch,
+			Command: baconfile.Commands(cmd...),
+			Pass:    baconfile.Commands(pass...),
+			Fail:    baconfile.Commands(fail...),
 		}
-		if bf != nil {
-			return bf, nil
-		}
-	}
-
-	return nil, errors.New("baconfile not found")
-}
-
-func newBaconForBaconfile(
-	c *cli.Context,
-	bc *baconfile.B,
-	targetName string,
-	args []string,
-) (*Bacon, error) {
-	if targetName == "" {
-		targetName = defaultTarget
-	}
 
-	target := bc.Targets[targetName]
-	if target == nil {
-		// If the default target isn't found, just use the first one available
-		if targetName == "default" {
-			for tn, t := range bc.Targets {
-				targetName = tn
-				target = t
-				break
-			}
-		}
+		targets[tName] = t
 
-		if target == nil {
-			return nil, fmt.Errorf("baconfile target not found: %s", targetName)
+		if !readYesNo(in, "Create another target?", false) {
+			break
 		}
 	}
 
-	includes := injectArgs(target.Watch, args)
-	excludes := injectArgs(target.Exclude, args)
-
-	exp := expander.New(target.Dir, includes, excludes)
-	w, err := watcher.New(exp)
-	if err != nil {
-		return nil, err
+	return &baconfile.B{
+		Targets: targets,
 	}
-
-	showOut := c.GlobalBool(showOutput)
-
-	commands := injectArgs(target.Command, args)
-	passCommands := injectArgs(target.Pass, args)
-	failCommands := injectArgs(target.Fail, args)
-
-	e := executor.New(
-		targetName,
-		commands,
-		passCommands,
-		failCommands,
-		target.Shell,
-		target.Dir,
-		showOut,
-	)
-
-	noNotify := c.GlobalBool(noNotify)
-
-	b := NewBacon(
-		w,
-		e,
-		showOut,
-		!noNotify,
-	)
-	return b, nil
 }
 
-func injectArgs(list []string, args []string) []string {
-	result := list[:]
-	for argIndex, arg := range args {
-		for i, li := range result {
-			result[i] = injectArg(li, argIndex+1, arg)
-		}
+// checkOverwrite fails when a file exists at the path, or a Baconfile of
+// another name exists in its directory, unless --force is given.
+func checkOverwrite(c *cli.Context, path string) error {
+	existing, err := existingBaconfile(path)
+	if err != nil {
+		return err
 	}
-	return result
+	if existing == "" || c.Bool(force) {
+		return nil
+	}
+	if existing == path {
+		return cli.NewExitError(fmt.Sprintf("baconfile already exists: %s: use --%s to overwrite it", path, force), 1)
+	}
+	return cli.NewExitError(fmt.Sprintf("baconfile already exists: %s: use --%s to write %s anyway", existing, force, path), 1)
 }
 
-func injectArg(str string, index int, value string) string {
-	varName := fmt.Sprintf("$%d", index)
-	return strings.Replace(str, varName, value, -1)
+// existingBaconfile returns the path when a file exists there, or else the
+// path of the Baconfile in its directory, if any. Baconfiles in the parent
+// directories don't count, since nested Baconfiles are allowed.
+func existingBaconfile(path string) (string, error) {
+	exists, err := util.Exists(path)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		return path, nil
+	}
+	return baconfile.FindIn(filepath.Dir(path))
 }
 
-func newRunCommand() *cli.Command {
+func newMigrateCommand() *cli.Command {
 	return &cli.Command{
-		Name:      "run",
-		Usage:     "Load configuration from a Baconfile target. The default target name is \"default\".",
-		ArgsUsage: "[target] [target arguments]",
+		Name:  "migrate",
+		Usage: "Rewrite a legacy Baconfile to the current schema version, printing the result by default",
 		Action: func(c *cli.Context) error {
-			bf, err := findBaconfile(c)
+			path, err := baconfilePath(c.String(baconFile))
 			if err != nil {
 				return err
 			}
 
-			var target string
-			args := c.Args()
-			if len(args) > 0 {
-				target = args[0]
-				args = args[1:]
+			in, err := os.ReadFile(path)
+			if err != nil {
+				return err
 			}
-
-			b, err := newBaconForBaconfile(c, bf, target, args)
+			out, err := baconfile.MigrateAs(in, baconfile.FormatOf(path))
 			if err != nil {
+				return fmt.Errorf("%s: %s", path, err.Error())
+			}
+
+			if !c.Bool(write) {
+				_, err = os.Stdout.Write(out)
 				return err
 			}
 
-			err = b.Run()
+			fi, err := os.Stat(path)
 			if err != nil {
 				return err
 			}
-
+			err = os.WriteFile(path, out, fi.Mode())
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Migrated %s to version %s\n", path, baconfile.Version)
 			return nil
 		},
 		Flags: []cli.Flag{
 			&cli.StringFlag{
 				Name:  baconFileLong,
-				Usage: "The `PATH` to the Baconfile to load (default: Baconfile, Baconfile.yml, Baconfile.yaml)",
+				Usage: "The `PATH` to the Baconfile to migrate (default: the Baconfile that run would load)",
+			},
+			&cli.BoolFlag{
+				Name:  writeLong,
+				Usage: "Rewrite the Baconfile in place",
 			},
 		},
 	}
 }
 
-func defCommands(app *cli.App) {
-	app.Commands = []cli.Command{
-		*newCommandCommand(),
-		*newListCommand(),
-		*newInitCommand(),
-		*newRunCommand(),
-	}
-}
-
-func newWatchFlags() []cli.Flag {
-	return []cli.Flag{
-		cli.StringSliceFlag{
-			Name:  watchLong,
-			Usage: "Watch the path `GLOB`. Can be repeated. (default: \"**/*\")",
-		},
-		cli.StringSliceFlag{
-			Name:  watchExcludeLong,
-			Usage: "Exclude path `GLOB` matches from being watched. Can be repeated. (default: \"**/.*\")",
+func newSchemaCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "schema",
+		Usage: "Print the JSON Schema of the Baconfile, for editor validation and completion",
+		Action: func(c *cli.Context) error {
+			out, err := baconfile.Schema()
+			if err != nil {
+				return err
+			}
+			_, err = fmt.Println(string(out))
+			return err
 		},
 	}
 }
 
-func newRunFlags() []cli.Flag {
-	return []cli.Flag{
-		cli.StringSliceFlag{
-			Name:  commandLong,
-			Usage: "Shell `CMD` to execute. Required. Can be repeated.",
-		},
+func newValidateCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "validate",
+		Usage: "Check a Baconfile, including that its directories, env files, and shells exist, and that its watch globs match files",
+		Action: func(c *cli.Context) error {
+			path, err := baconfilePath(c.String(baconFile))
+			if err != nil {
+				return err
+			}
+			if err := requireBaconfile(path); err != nil {
+				return err
+			}
+			_, errs, err := baconfile.Inspect(path)
+			if err != nil {
+				return cli.NewExitError(fmt.Sprintf("%s: %s", path, err.Error()), 1)
+			}
+			if len(errs) == 0 {
+				fmt.Printf("%s is valid\n", path)
+				return nil
+			}
+			for _, err := range errs {
+				_, _ = fmt.Fprintf(os.Stderr, "%s: %s\n", path, err.Error())
+			}
+			if len(errs) == 1 {
+				return cli.NewExitError("1 problem found", 1)
+			}
+			return cli.NewExitError(fmt.Sprintf("%d problems found", len(errs)), 1)
+		},
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  baconFileLong,
+				Usage: "The `PATH` to the Baconfile to check (default: the Baconfile that run would load)",
+			},
+		},
+	}
+}
+
+func newExplainCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "explain",
+		Usage:     "Print a Baconfile target as it would run, with its globs normalized and its variables interpolated",
+		ArgsUsage: "[target] [--param value...] [target arguments]",
+		Action: func(c *cli.Context) error {
+			bf, err := findBaconfile(c.String(baconFile))
+			if err != nil {
+				return err
+			}
+
+			targetName, args := commandArgs(c.Command.Flags, c.Parent().Args().Tail())
+			targetName, target, args, err := resolveTarget(c, bf, targetName, args)
+			if err != nil {
+				return err
+			}
+			envs, err := bf.Environ(target)
+			if err != nil {
+				return err
+			}
+
+			return explainTarget(os.Stdout, targetName, target, bf.UsesIgnoreFiles(target), envs, args)
+		},
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  baconFileLong,
+				Usage: "The `PATH` to the Baconfile to load (default: Baconfile, Baconfile.yml, Baconfile.yaml, Baconfile.toml, Baconfile.json)",
+			},
+			&cli.StringSliceFlag{
+				Name:  variable,
+				Usage: "Override a Baconfile variable, given as `KEY=VALUE`. Can be repeated.",
+			},
+		},
+	}
+}
+
+// explainTarget prints the resolved target, with its globs as the expander
+// evaluates them, and the commands as they would run.
+func explainTarget(
+	out io.Writer,
+	targetName string,
+	target *baconfile.Target,
+	ignoreFiles bool,
+	envs []string,
+	args []string,
+) error {
+	tw := tabwriter.NewWriter(out, 1, 8, 2, ' ', 0)
+	field := func(name string, value interface{}) {
+		_, _ = fmt.Fprintf(tw, "%s:\t%v\n", name, value)
+	}
+	list := func(name string, values []string) {
+		for i, v := range values {
+			if i > 0 {
+				name = ""
+			} else {
+				name += ":"
+			}
+			_, _ = fmt.Fprintf(tw, "%s\t%s\n", name, v)
+		}
+	}
+	commands := func(name string, cmds []*executor.Command) {
+		var runs []string
+		for _, c := range cmds {
+			runs = append(runs, strings.TrimSpace(strings.Join(append(c.Env, c.Run), " ")))
+		}
+		list(name, runs)
+	}
+
+	shell := target.Shell
+	if shell == "" {
+		shell = executor.DefaultShell
+	}
+
+	field("target", targetName)
+	field("dir", target.Dir)
+	watch, exclude := expander.NormalizeGlobs(target.Dir, target.Watch, target.Exclude)
+	list("watch", watch)
+	list("exclude", exclude)
+	field("ignore files", ignoreFiles)
+	field("shell", shell)
+	list("env", envs)
+	commands("command", newCommands(target.Command))
+	commands("pass", newCommands(target.Pass))
+	commands("fail", newCommands(target.Fail))
+	for i, r := range target.Rules {
+		name := fmt.Sprintf("rule %d", i+1)
+		watch, exclude := expander.NormalizeGlobs(target.Dir, r.Watch, r.Exclude)
+		list(name+" watch", watch)
+		list(name+" exclude", exclude)
+		commands(name+" command", newCommands(r.Command))
+	}
+	return tw.Flush()
+}
+
+// targetInfo describes a Baconfile target in the output of the targets
+// command.
+type targetInfo struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Dir         string   `json:"dir"`
+	Watch       []string `json:"watch"`
+	Files       *int     `json:"files"`
+	Error       string   `json:"error,omitempty"`
+}
+
+func newTargetsCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "targets",
+		Usage: "List the targets of a Baconfile, with the number of files that each watches",
+		Action: func(c *cli.Context) error {
+			bf, err := findBaconfile(c.String(baconFile))
+			if err != nil {
+				return err
+			}
+
+			infos := listTargets(bf)
+
+			if c.Bool(jsonOut) {
+				out, err := json.MarshalIndent(infos, "", "  ")
+				if err != nil {
+					return err
+				}
+				_, err = fmt.Println(string(out))
+				return err
+			}
+			return printTargets(os.Stdout, infos)
+		},
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  baconFileLong,
+				Usage: "The `PATH` to the Baconfile to load (default: Baconfile, Baconfile.yml, Baconfile.yaml, Baconfile.toml, Baconfile.json)",
+			},
+			&cli.BoolFlag{
+				Name:  jsonOut,
+				Usage: "Print the targets as JSON",
+			},
+		},
+	}
+}
+
+// listTargets describes the targets of the Baconfile that can be run, sorted
+// by name. A target that can't be interpolated, or whose files can't be
+// listed, is described with the error. Globs that depend on
+// positional arguments, or on parameters without defaults, are described as
+// written, and the number of files of their target is unknown.
+func listTargets(bf *baconfile.B) []*targetInfo {
+	var names []string
+	for name, t := range bf.Targets {
+		if !t.Abstract {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	infos := []*targetInfo{}
+	for _, name := range names {
+		raw := bf.Targets[name]
+		target, err := bf.Interpolate(raw, nil, nil, nil)
+		if err != nil {
+			// Such as when an environment variable isn't set
+			infos = append(infos, &targetInfo{
+				Name:        name,
+				Description: raw.Description,
+				Dir:         bf.Dir(raw),
+				Watch:       raw.AllWatch(),
+				Error:       err.Error(),
+			})
+			continue
+		}
+		known := !raw.DependsOnArgs(raw.Dir)
+		if !known {
+			target.Dir = raw.Dir
+		}
+		target.Dir = bf.Dir(target)
+
+		watch := append([]string{}, target.AllWatch()...)
+		for i, g := range raw.AllWatch() {
+			if raw.DependsOnArgs(g) {
+				watch[i] = g
+				known = false
+			}
+		}
+		excludes := raw.Exclude
+		for _, r := range raw.Rules {
+			excludes = append(excludes[:len(excludes):len(excludes)], r.Exclude...)
+		}
+		for _, g := range excludes {
+			if raw.DependsOnArgs(g) {
+				known = false
+			}
+		}
+
+		info := &targetInfo{
+			Name:        name,
+			Description: target.Description,
+			Dir:         target.Dir,
+			Watch:       watch,
+		}
+		if known {
+			files, err := newTargetExpander(bf, target).List()
+			if err != nil {
+				info.Error = err.Error()
+			}
+			count := len(files)
+			info.Files = &count
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+func printTargets(out io.Writer, infos []*targetInfo) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(out, 1, 8, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "TARGET\tDESCRIPTION\tDIR\tFILES\tWATCH")
+	for _, info := range infos {
+		dir := info.Dir
+		if rel, err := filepath.Rel(cwd, dir); err == nil {
+			dir = rel
+		}
+		files := "?"
+		if info.Error != "" {
+			files = "-"
+		} else if info.Files != nil {
+			files = strconv.Itoa(*info.Files)
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", info.Name, info.Description, dir, files, strings.Join(info.Watch, " "))
+	}
+	return tw.Flush()
+}
+
+func newCommandCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "command",
+		Usage: "Execute commands once and exit. Useful for testing a command chain.",
+		Action: func(c *cli.Context) error {
+			e, err := newExecutor(c)
+			if err != nil {
+				return err
+			}
+
+			r := e.RunCommands("", nil)
+			if !r.Passing {
+				return cli.NewExitError("", 1)
+			}
+
+			return nil
+		},
+		Flags: newRunFlags(),
+	}
+}
+
+func loadBaconfile(path string) (*baconfile.B, error) {
+	if err := requireBaconfile(path); err != nil {
+		return nil, err
+	}
+
+	bf, err := baconfile.Load(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return bf, nil
+}
+
+// requireBaconfile fails when there's no Baconfile at the path.
+func requireBaconfile(path string) error {
+	exists, err := util.Exists(path)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("baconfile not found: %s", path)
+	}
+	return nil
+}
+
+// findBaconfile loads the Baconfile at the path, or else the first found in
+// the working directory or its parents.
+func findBaconfile(path string) (*baconfile.B, error) {
+	path, err := baconfilePath(path)
+	if err != nil {
+		return nil, err
+	}
+	return loadBaconfile(path)
+}
+
+// baconfilePath returns the path, or else that of the first Baconfile found
+// in the working directory or its parents.
+func baconfilePath(path string) (string, error) {
+	if path != "" {
+		return path, nil
+	}
+	found, err := baconfile.Find(".")
+	if err != nil {
+		return "", err
+	}
+	if found == "" {
+		return "", errors.New("baconfile not found")
+	}
+	return found, nil
+}
+
+// resolveTarget returns the named target, or the default, with its variables
+// and parameters interpolated and its directory rooted, along with the
+// positional arguments that remain after the parameters.
+func resolveTarget(
+	c *cli.Context,
+	bc *baconfile.B,
+	targetName string,
+	args []string,
+) (string, *baconfile.Target, []string, error) {
+	if targetName == "" {
+		targetName = defaultTarget
+	}
+
+	target := bc.Targets[targetName]
+	if target == nil {
+		// If the default target isn't found, just use the first one available
+		if targetName == "default" {
+			var names []string
+			for tn, t := range bc.Targets {
+				if !t.Abstract {
+					names = append(names, tn)
+				}
+			}
+			sort.Strings(names)
+			if len(names) > 0 {
+				targetName = names[0]
+				target = bc.Targets[targetName]
+			}
+		}
+
+		if target == nil {
+			return "", nil, nil, fmt.Errorf("baconfile target not found: %s", targetName)
+		}
+	}
+	if target.Abstract {
+		return "", nil, nil, fmt.Errorf("baconfile target is abstract: %s", targetName)
+	}
+
+	vars, err := parseVars(c.StringSlice(variable))
+	if err != nil {
+		return "", nil, nil, err
+	}
+	params, args, err := parseParams(c, target, args)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("target '%s': %s", targetName, err.Error())
+	}
+	target, err = bc.Interpolate(target, vars, params, args)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("target '%s': %s", targetName, err.Error())
+	}
+	target.Dir = bc.Dir(target)
+
+	return targetName, target, args, nil
+}
+
+func newBaconForBaconfile(
+	c *cli.Context,
+	bc *baconfile.B,
+	targetName string,
+	args []string,
+) (*Bacon, error) {
+	targetName, target, args, err := resolveTarget(c, bc, targetName, args)
+	if err != nil {
+		return nil, err
+	}
+
+	w, e, err := newTargetWatcher(c, bc, targetName, target, args)
+	if err != nil {
+		return nil, err
+	}
+
+	showOut := c.GlobalBool(showOutput)
+	noNotify := c.GlobalBool(noNotify)
+
+	statusFmt := c.GlobalString(statusFormat)
+	if statusFmt == "" {
+		statusFmt = target.StatusFormat
+	}
+	statusTpl, err := newStatusTemplate(statusFmt)
+	if err != nil {
+		return nil, err
+	}
+
+	reporters := newReporters(
+		c.GlobalBool(title),
+		c.GlobalBool(tmux),
+		c.GlobalString(statusFile),
+	)
+
+	colorOut, interactive, err := terminalMode(c.GlobalString(color))
+	if err != nil {
+		return nil, err
+	}
+
+	b := NewBacon(
+		w,
+		e,
+		showOut,
+		!noNotify,
+		colorOut,
+		interactive,
+		statusTpl,
+		reporters,
+	)
+	return b, nil
+}
+
+// resolvedTarget is a target as it runs, for detecting changes on reload.
+type resolvedTarget struct {
+	name        string
+	target      *baconfile.Target
+	args        []string
+	env         []string
+	ignoreFiles bool
+}
+
+func newResolvedTarget(c *cli.Context, bc *baconfile.B, targetName string, args []string) (*resolvedTarget, error) {
+	name, target, args, err := resolveTarget(c, bc, targetName, args)
+	if err != nil {
+		return nil, err
+	}
+	env, err := bc.Environ(target)
+	if err != nil {
+		return nil, err
+	}
+	return &resolvedTarget{name, target, args, env, bc.UsesIgnoreFiles(target)}, nil
+}
+
+func (rt *resolvedTarget) equal(other *resolvedTarget) bool {
+	return rt.name == other.name &&
+		rt.target.Equal(other.target) &&
+		reflect.DeepEqual(rt.args, other.args) &&
+		reflect.DeepEqual(rt.env, other.env) &&
+		rt.ignoreFiles == other.ignoreFiles
+}
+
+// newReloader watches the files read to load the Baconfile at the path, and
+// returns a function that reloads it, creating a new watcher and executor for
+// the target when its configuration has changed, and a new watcher of the
+// Baconfile when the files read to load it have changed.
+func newReloader(
+	c *cli.Context,
+	path string,
+	bc *baconfile.B,
+	targetName string,
+	args []string,
+) (*watcher.W, ReloadFunc, error) {
+	current, err := newResolvedTarget(c, bc, targetName, args)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	files := bc.Files()
+	w, err := newBaconfileWatcher(c, files)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	reload := func() (*Reload, error) {
+		bc, err := loadBaconfile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		r := &Reload{}
+		if !reflect.DeepEqual(bc.Files(), files) {
+			r.Reloads, err = newBaconfileWatcher(c, bc.Files())
+			if err != nil {
+				return nil, err
+			}
+			files = bc.Files()
+		}
+
+		// Keep watching the new files, even if the target is broken
+		next, err := newResolvedTarget(c, bc, targetName, args)
+		if err != nil {
+			return r, err
+		}
+		if !next.equal(current) {
+			r.W, r.E, err = newTargetWatcher(c, bc, next.name, next.target, next.args)
+			if err != nil {
+				return r, err
+			}
+			current = next
+		}
+		return r, nil
+	}
+	return w, reload, nil
+}
+
+// newBaconfileWatcher creates a watcher of the files, which are the absolute
+// paths of Baconfiles.
+func newBaconfileWatcher(c *cli.Context, files []string) (*watcher.W, error) {
+	// Re-include hidden Baconfiles, which are excluded by default
+	var excludes []string
+	for _, f := range files {
+		excludes = append(excludes, "!"+f)
+	}
+	exp := expander.New(filepath.Dir(files[0]), files, excludes)
+	polls, interval := pollOptions(c)
+	return newWatcher(exp, polls, interval)
+}
+
+// newTargetExpander creates the expander of the files that the resolved
+// target watches.
+func newTargetExpander(bc *baconfile.B, target *baconfile.Target) *expander.E {
+	exp := expander.New(target.Dir, target.AllWatch(), target.Exclude)
+	if bc.UsesIgnoreFiles(target) {
+		exp.UseIgnoreFiles()
+	}
+	if target.FollowsSymlinks() {
+		exp.FollowSymlinks()
+	}
+	if target.IgnoresCase() {
+		exp.IgnoreCase()
+	}
+	return exp
+}
+
+// newTargetWatcher creates the watcher and executor of the resolved target.
+func newTargetWatcher(
+	c *cli.Context,
+	bc *baconfile.B,
+	targetName string,
+	target *baconfile.Target,
+	args []string,
+) (*watcher.W, *executor.E, error) {
+	exp := newTargetExpander(bc, target)
+
+	polls, interval, err := target.PollInterval()
+	if err != nil {
+		return nil, nil, err
+	}
+	if pollsOption, intervalOption := pollOptions(c); pollsOption {
+		polls = true
+		if c.GlobalIsSet(pollInterval) {
+			interval = intervalOption
+		}
+	}
+
+	w, err := newWatcher(exp, polls, interval)
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.GlobalBool(contentHash) || target.HashesContent() {
+		w.UseContentHashes()
+	}
+
+	showOut := c.GlobalBool(showOutput)
+
+	commands := newCommands(target.Command)
+	passCommands := newCommands(target.Pass)
+	failCommands := newCommands(target.Fail)
+
+	e := executor.New(
+		targetName,
+		commands,
+		passCommands,
+		failCommands,
+		target.Shell,
+		target.Dir,
+		showOut,
+	)
+	goPkgs := target.GoPackages
+	if c.GlobalIsSet(goPackages) {
+		goPkgs = c.GlobalString(goPackages)
+		if err := gopackages.CheckMode(goPkgs); err != nil {
+			return nil, nil, cli.NewExitError(err.Error(), 1)
+		}
+	}
+	e.UseGoPackages(goPkgs)
+
+	envs, err := bc.Environ(target)
+	if err != nil {
+		return nil, nil, err
+	}
+	flagEnvs, err := parseEnv(c.GlobalStringSlice(env))
+	if err != nil {
+		return nil, nil, cli.NewExitError(err.Error(), 1)
+	}
+	e.UseEnv(append(envs, flagEnvs...))
+
+	for _, r := range target.Rules {
+		sel := expander.New(
+			target.Dir,
+			r.Watch,
+			r.Exclude,
+		)
+		if target.IgnoresCase() {
+			sel.IgnoreCase()
+		}
+		e.AddRule(sel, newCommands(r.Command))
+	}
+
+	return w, e, nil
+}
+
+// parseVars parses a list of KEY=VALUE variable assignments into a map.
+func parseVars(list []string) (map[string]string, error) {
+	result := make(map[string]string)
+	for _, kv := range list {
+		i := strings.Index(kv, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid variable assignment: %s: expected KEY=VALUE", kv)
+		}
+		result[kv[:i]] = kv[i+1:]
+	}
+	return result, nil
+}
+
+// commandArgs returns the target name, and the arguments that follow it,
+// from the words of the command line after the command name, skipping the
+// command's options. The words are read as given, since the cli package
+// moves a "--" ahead of the target. The arguments keep the "--", after
+// which words are positional arguments, even when they look like
+// parameters.
+func commandArgs(flags []cli.Flag, words []string) (string, []string) {
+	var target string
+	var args []string
+	for i := 0; i < len(words); i++ {
+		w := words[i]
+		switch {
+		case w == "--":
+			rest := words[i+1:]
+			if target == "" && len(rest) > 0 {
+				target, rest = rest[0], rest[1:]
+			}
+			if len(rest) > 0 {
+				args = append(append(args, "--"), rest...)
+			}
+			return target, args
+		case isFlag(flags, w):
+			if takesValue(flags, w) {
+				i++
+			}
+		case target == "":
+			target = w
+		default:
+			args = append(args, w)
+		}
+	}
+	return target, args
+}
+
+// parseParams separates the target's "--name value" and "--name=value"
+// parameters from its positional arguments, which are all of the arguments
+// after "--". Targets that declare no parameters take only positional
+// arguments.
+func parseParams(c *cli.Context, target *baconfile.Target, args []string) (map[string]string, []string, error) {
+	if len(target.Params) == 0 {
+		for i, arg := range args {
+			if arg == "--" {
+				return nil, append(args[:i:i], args[i+1:]...), nil
+			}
+		}
+		return nil, args, nil
+	}
+
+	for _, f := range c.Command.Flags {
+		for _, name := range strings.Split(f.GetName(), ",") {
+			if target.Param(strings.TrimSpace(name)) != nil {
+				return nil, nil, fmt.Errorf("parameter --%s conflicts with a run option", strings.TrimSpace(name))
+			}
+		}
+	}
+
+	given := make(map[string]string)
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			positional = append(positional, args[i+1:]...)
+			break
+		}
+		if !strings.HasPrefix(arg, "--") {
+			positional = append(positional, arg)
+			continue
+		}
+
+		name := strings.TrimPrefix(arg, "--")
+		if eq := strings.Index(name, "="); eq >= 0 {
+			given[name[:eq]] = name[eq+1:]
+			continue
+		}
+		if i+1 >= len(args) {
+			return nil, nil, fmt.Errorf("missing value for parameter: --%s", name)
+		}
+		given[name] = args[i+1]
+		i++
+	}
+
+	params, err := target.ParamValues(given)
+	if err != nil {
+		return nil, nil, err
+	}
+	return params, positional, nil
+}
+
+// parseEnv checks that each environment variable is given as KEY=VALUE.
+func parseEnv(list []string) ([]string, error) {
+	for _, kv := range list {
+		if strings.Index(kv, "=") <= 0 {
+			return nil, fmt.Errorf("invalid environment variable: %s: expected KEY=VALUE", kv)
+		}
+	}
+	return list, nil
+}
+
+// newCommands creates executor commands from Baconfile commands.
+func newCommands(cmds []*baconfile.Command) []*executor.Command {
+	var result []*executor.Command
+	for _, c := range cmds {
+		result = append(result, &executor.Command{
+			Run: c.Run,
+			Env: c.Environ(),
+		})
+	}
+	return result
+}
+
+// showRunHelp shows the run command's help, followed by the parameters of
+// the named target of the Baconfile, if any.
+func showRunHelp(c *cli.Context, bfPath string, targetName string) error {
+	if err := cli.ShowCommandHelp(c, "run"); err != nil {
+		return err
+	}
+	if targetName == "" {
+		return nil
+	}
+	return printParams(c, bfPath, targetName)
+}
+
+// parseRunHelp parses the run command's arguments when they ask for help
+// before the target, which the cli package fails to parse, returning the
+// Baconfile path and the target name.
+func parseRunHelp(c *cli.Context) (string, string) {
+	set := flag.NewFlagSet("run", flag.ContinueOnError)
+	set.SetOutput(io.Discard)
+	for _, f := range c.Command.Flags {
+		f.Apply(set)
+	}
+	set.Bool("help", false, "")
+	set.Bool("h", false, "")
+	_ = set.Parse(c.Parent().Args().Tail())
+
+	var bfPath string
+	if f := set.Lookup(baconFile); f != nil {
+		bfPath = f.Value.String()
+	}
+	return bfPath, set.Arg(0)
+}
+
+// wantsHelp answers whether the arguments ask for help, before any "--".
+// The run command's raw arguments are given, since the cli package moves
+// "--" ahead of the target when reordering them.
+func wantsHelp(args []string) bool {
+	for _, arg := range args {
+		switch arg {
+		case "--":
+			return false
+		case "-h", "--help":
+			return true
+		}
+	}
+	return false
+}
+
+// printParams prints the parameters declared by the named target of the
+// Baconfile.
+func printParams(c *cli.Context, bfPath string, targetName string) error {
+	bf, err := findBaconfile(bfPath)
+	if err != nil {
+		return err
+	}
+	target := bf.Targets[targetName]
+	if target == nil {
+		return fmt.Errorf("baconfile target not found: %s", targetName)
+	}
+
+	out := c.App.Writer
+	if len(target.Params) == 0 {
+		_, _ = fmt.Fprintf(out, "\nTarget '%s' has no parameters.\n", targetName)
+		return nil
+	}
+
+	_, _ = fmt.Fprintf(out, "\nPARAMETERS OF TARGET '%s':\n", targetName)
+	tw := tabwriter.NewWriter(out, 1, 8, 2, ' ', 0)
+	for _, p := range target.Params {
+		usage := p.Description
+		if p.Default != nil {
+			usage = strings.TrimSpace(fmt.Sprintf("%s (default: %q)", usage, *p.Default))
+		} else {
+			usage = strings.TrimSpace(usage + " (required)")
+		}
+		_, _ = fmt.Fprintf(tw, "   --%s value\t%s\n", p.Name, usage)
+	}
+	return tw.Flush()
+}
+
+func newRunCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "run",
+		Usage:     "Load configuration from a Baconfile target. The default target name is \"default\".",
+		ArgsUsage: "[target] [--param value...] [target arguments]",
+		// Help is handled here, rather than by the cli package, so that
+		// "run <target> --help" can list the target's parameters
+		HideHelp: true,
+		OnUsageError: func(c *cli.Context, err error, _ bool) error {
+			// The flag package reports an undefined --help flag as ErrHelp
+			if err == flag.ErrHelp {
+				bfPath, target := parseRunHelp(c)
+				return showRunHelp(c, bfPath, target)
+			}
+			_, _ = fmt.Fprintln(c.App.Writer, "Incorrect Usage:", err.Error())
+			_, _ = fmt.Fprintln(c.App.Writer)
+			_ = cli.ShowCommandHelp(c, "run")
+			return err
+		},
+		Action: func(c *cli.Context) error {
+			target, args := commandArgs(c.Command.Flags, c.Parent().Args().Tail())
+			if wantsHelp(c.Parent().Args().Tail()) {
+				return showRunHelp(c, c.String(baconFile), target)
+			}
+
+			path, err := baconfilePath(c.String(baconFile))
+			if err != nil {
+				return err
+			}
+			bf, err := loadBaconfile(path)
+			if err != nil {
+				return err
+			}
+
+			b, err := newBaconForBaconfile(c, bf, target, args)
+			if err != nil {
+				return err
+			}
+
+			if !c.Bool(noReload) {
+				w, reload, err := newReloader(c, path, bf, target, args)
+				if err != nil {
+					return err
+				}
+				b.UseReload(w, reload)
+			}
+
+			err = b.Run()
+			if err != nil {
+				return err
+			}
+
+			return nil
+		},
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  baconFileLong,
+				Usage: "The `PATH` to the Baconfile to load (default: Baconfile, Baconfile.yml, Baconfile.yaml, Baconfile.toml, Baconfile.json)",
+			},
+			&cli.StringSliceFlag{
+				Name:  variable,
+				Usage: "Override a Baconfile variable, given as `KEY=VALUE`. Can be repeated.",
+			},
+			&cli.BoolFlag{
+				Name:  noReload,
+				Usage: "Don't reload the Baconfile when it changes",
+			},
+		},
+	}
+}
+
+func defCommands(app *cli.App) {
+	app.Commands = []cli.Command{
+		*newCommandCommand(),
+		*newListCommand(),
+		*newInitCommand(),
+		*newRunCommand(),
+		*newMigrateCommand(),
+		*newSchemaCommand(),
+		*newValidateCommand(),
+		*newExplainCommand(),
+		*newTargetsCommand(),
+		*newCompletionCommand(),
+		*newCompleteCommand(),
+	}
+}
+
+func newWatchFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringSliceFlag{
+			Name:  watchLong,
+			Usage: "Watch the path `GLOB`. Can be repeated. (default: \"**/*\")",
+		},
+		cli.StringSliceFlag{
+			Name:  watchExcludeLong,
+			Usage: "Exclude path `GLOB` matches from being watched. Can be repeated. (default: \"**/.*\")",
+		},
+		cli.BoolFlag{
+			Name:  ignoreFiles,
+			Usage: "Exclude files ignored by .gitignore, .ignore, and .baconignore files",
+		},
+		cli.BoolFlag{
+			Name:  followSymlinks,
+			Usage: "Follow symlinks to directories and files when matching and watching",
+		},
+		cli.BoolFlag{
+			Name:  ignoreCase,
+			Usage: "Match globs case-insensitively",
+		},
+	}
+}
+
+func newRunFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringSliceFlag{
+			Name:  commandLong,
+			Usage: "Shell `CMD` to execute. Required. Can be repeated.",
+		},
 		cli.StringSliceFlag{
 			Name:  passCommandLong,
 			Usage: "Run the `CMD` when commands pass. Can be repeated.",
@@ -480,10 +1631,18 @@ func newRunFlags() []cli.Flag {
 			Name:  failCommandLong,
 			Usage: "Run the `CMD` when commands fail. Can be repeated.",
 		},
+		cli.StringFlag{
+			Name:  goPackages,
+			Usage: "Pass the Go packages affected by a change to commands. `MODE` is \"changed\" or \"dependents\"",
+		},
 		cli.StringFlag{
 			Name:  shell,
 			Usage: "The shell with which to interpret commands. (default: \"bash\")",
 		},
+		cli.StringSliceFlag{
+			Name:  env,
+			Usage: "Set an environment variable for commands, given as `KEY=VALUE`. Can be repeated.",
+		},
 	}
 }
 
@@ -513,6 +1672,40 @@ func newCliApp() *cli.App {
 			Name:  noNotify,
 			Usage: "Disable system notifications",
 		},
+		cli.StringFlag{
+			Name:  statusFormat,
+			Usage: "Render the status line with the Go `TEMPLATE`",
+		},
+		cli.BoolFlag{
+			Name:  title,
+			Usage: "Set the terminal window title to the command status",
+		},
+		cli.BoolFlag{
+			Name:  tmux,
+			Usage: "Export the command status to the tmux \"" + tmuxStatusOption + "\" option",
+		},
+		cli.StringFlag{
+			Name:  statusFile,
+			Usage: "Write the command status of each target to the file at `PATH`",
+		},
+		cli.BoolFlag{
+			Name:  poll,
+			Usage: "Poll files for changes, for file systems that don't support change notifications",
+		},
+		cli.DurationFlag{
+			Name:  pollInterval,
+			Value: watcher.DefaultPollInterval,
+			Usage: "Poll files for changes every `DURATION`, implying --poll",
+		},
+		cli.BoolFlag{
+			Name:  contentHash,
+			Usage: "Ignore changes that leave file content as it was, such as touching a file",
+		},
+		cli.StringFlag{
+			Name:  color,
+			Value: colorAuto,
+			Usage: "Colour the status line: `WHEN` is auto, always, or never",
+		},
 	}
 
 	app.Flags = append(app.Flags, newWatchFlags()...)
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"github.com/troykinsella/bacon/baconfile"
+	"github.com/troykinsella/bacon/watcher"
+	"github.com/urfave/cli"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestExistingBaconfile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"repo/.git/HEAD":          "",
+		"repo/Baconfile.yml":      "",
+		"repo/sub/.keep":          "",
+		"repo/other.yml":          "",
+		"empty/.git/HEAD":         "",
+		"empty/sub/.keep":         "",
+		"empty/sub/Baconfile.bak": "",
+	})
+
+	var tests = []struct {
+		path string
+		exp  string
+	}{
+		{"repo/Baconfile.yml", "repo/Baconfile.yml"},
+		{"repo/Baconfile.toml", "repo/Baconfile.yml"},
+		{"repo/sub/Baconfile", ""},
+		{"repo/sub/Baconfile.toml", ""},
+		{"repo/other.yml", "repo/other.yml"},
+		{"empty/Baconfile", ""},
+		{"empty/sub/Baconfile.json", ""},
+	}
+
+	for i, test := range tests {
+		r, err := existingBaconfile(filepath.Join(dir, test.path))
+		if err != nil {
+			t.Errorf("%d. unexpected error: %s\n", i, err.Error())
+			continue
+		}
+		exp := ""
+		if test.exp != "" {
+			exp = filepath.Join(dir, test.exp)
+		}
+		if r != exp {
+			t.Errorf("%d. unexpected result:\nexpected=%s,\nactual=%s\n", i, exp, r)
+		}
+	}
+}
+
+func TestListTargets(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"Baconfile": `---
+version: "2"
+targets:
+  base: { abstract: true, watch: [x], command: [y] }
+  all: { description: All, watch: [ "src/*.go" ], command: [y] }
+  one:
+    params: [ { name: pkg }, { name: ext, default: go } ]
+    watch: [ "src/${pkg}/*.${ext}", "src/*.${ext}" ]
+    command: [y]
+  arg: { watch: [ "$1/*.go" ], command: [y] }
+  exclude: { watch: [ "src/*.go" ], exclude: [ "$1" ], command: [y] }
+  missing: { dir: nowhere, watch: [ "*.go" ], command: [y] }
+  deploy: { dir: "${env:BACON_TEST_UNSET}", watch: [ "*.go" ], command: [y] }
+`,
+		"src/a.go":     "",
+		"src/b.go":     "",
+		"src/c.txt":    "",
+		"src/pkg/d.go": "",
+	})
+
+	bf, err := baconfile.Load(filepath.Join(dir, "Baconfile"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	infos := listTargets(bf)
+
+	var tests = []struct {
+		name  string
+		dir   string
+		watch string
+		files string
+		err   bool
+	}{
+		{"all", dir, "src/*.go", "2", false},
+		{"arg", dir, "$1/*.go", "?", false},
+		{"deploy", filepath.Join(dir, "${env:BACON_TEST_UNSET}"), "*.go", "?", true},
+		{"exclude", dir, "src/*.go", "?", false},
+		{"missing", filepath.Join(dir, "nowhere"), "*.go", "0", false},
+		{"one", dir, "src/${pkg}/*.${ext} src/*.go", "?", false},
+	}
+
+	if len(infos) != len(tests) {
+		t.Fatalf("unexpected targets: %d", len(infos))
+	}
+	for i, test := range tests {
+		info := infos[i]
+		files := "?"
+		if info.Files != nil {
+			files = strconv.Itoa(*info.Files)
+		}
+		watch := strings.Join(info.Watch, " ")
+		if info.Name != test.name || info.Dir != test.dir || watch != test.watch || files != test.files || (info.Error != "") != test.err {
+			t.Errorf("%d. unexpected result:\nexpected=%s %s %s %s %t,\nactual=%s %s %s %s %s\n", i, test.name, test.dir, test.watch, test.files, test.err, info.Name, info.Dir, watch, files, info.Error)
+		}
+	}
+}
+
+func TestPrintTargets(t *testing.T) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	two := 2
+
+	var out bytes.Buffer
+	err = printTargets(&out, []*targetInfo{
+		{Name: "all", Description: "All", Dir: filepath.Join(cwd, "src"), Watch: []string{"*.go", "*.mod"}, Files: &two},
+		{Name: "arg", Dir: cwd, Watch: []string{"$1/*.go"}},
+		{Name: "missing", Dir: filepath.Join(cwd, "nowhere"), Watch: []string{"*.go"}, Error: "no such file or directory"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	exp := `TARGET   DESCRIPTION  DIR      FILES  WATCH
+all      All          src      2      *.go *.mod
+arg                   .        ?      $1/*.go
+missing               nowhere  -      *.go
+`
+	if out.String() != exp {
+		t.Errorf("unexpected result:\nexpected=%s,\nactual=%s\n", exp, out.String())
+	}
+}
+
+func TestColorMode(t *testing.T) {
+	var tests = []struct {
+		mode        string
+		interactive bool
+		noColor     string
+		exp         bool
+	}{
+		{"", true, "", true},
+		{"auto", true, "", true},
+		{"auto", true, "1", false},
+		{"auto", false, "", false},
+		{"always", false, "1", true},
+		{"never", true, "", false},
+	}
+
+	for i, test := range tests {
+		t.Setenv("NO_COLOR", test.noColor)
+		r, err := colorMode(test.mode, test.interactive)
+		if err != nil {
+			t.Errorf("%d. unexpected error: %s\n", i, err.Error())
+			continue
+		}
+		if r != test.exp {
+			t.Errorf("%d. unexpected result:\nexpected=%t,\nactual=%t\n", i, test.exp, r)
+		}
+	}
+
+	if _, err := colorMode("sometimes", true); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+func TestPollOptions(t *testing.T) {
+	app := newCliApp()
+
+	var tests = []struct {
+		args        []string
+		expPolls    bool
+		expInterval time.Duration
+	}{
+		{[]string{}, false, watcher.DefaultPollInterval},
+		{[]string{"--poll"}, true, watcher.DefaultPollInterval},
+		{[]string{"--poll-interval", "500ms"}, true, 500 * time.Millisecond},
+		{[]string{"--poll", "--poll-interval", "2s"}, true, 2 * time.Second},
+	}
+
+	for i, test := range tests {
+		set := flag.NewFlagSet("test", flag.ContinueOnError)
+		for _, f := range app.Flags {
+			f.Apply(set)
+		}
+		if err := set.Parse(test.args); err != nil {
+			t.Fatal(err)
+		}
+		polls, interval := pollOptions(cli.NewContext(app, set, nil))
+		if polls != test.expPolls || interval != test.expInterval {
+			t.Errorf("%d. unexpected result:\nexpected=%t, %s,\nactual=%t, %s\n", i, test.expPolls, test.expInterval, polls, interval)
+		}
+	}
+}
+
+func TestCommandArgs(t *testing.T) {
+	flags := newRunCommand().Flags
+
+	var tests = []struct {
+		words     []string
+		expTarget string
+		expArgs   []string
+	}{
+		{[]string{}, "", nil},
+		{[]string{"t"}, "t", nil},
+		{[]string{"-b", "B", "t", "a"}, "t", []string{"a"}},
+		{[]string{"t", "--baconfile=B", "--pkg", "x", "a"}, "t", []string{"--pkg", "x", "a"}},
+		{[]string{"t", "--no-reload", "a", "--", "--pkg", "-b"}, "t", []string{"a", "--", "--pkg", "-b"}},
+		{[]string{"-b", "B", "--", "t", "--pkg"}, "t", []string{"--", "--pkg"}},
+		{[]string{"t", "--"}, "t", nil},
+	}
+
+	for i, test := range tests {
+		target, args := commandArgs(flags, test.words)
+		if target != test.expTarget || strings.Join(args, " ") != strings.Join(test.expArgs, " ") {
+			t.Errorf("%d. unexpected result:\nexpected=%s %v,\nactual=%s %v\n", i, test.expTarget, test.expArgs, target, args)
+		}
+	}
+}
+
+func TestParseParams(t *testing.T) {
+	app := newCliApp()
+	c := cli.NewContext(app, flag.NewFlagSet("run", flag.ContinueOnError), nil)
+	c.Command = *newRunCommand()
+
+	def := "./..."
+	withParams := &baconfile.Target{Params: []*baconfile.Param{{Name: "pkg", Default: &def}}}
+	noParams := &baconfile.Target{}
+
+	var tests = []struct {
+		target  *baconfile.Target
+		args    []string
+		expPkg  string
+		expArgs []string
+		err     string
+	}{
+		{withParams, []string{"a"}, "./...", []string{"a"}, ""},
+		{withParams, []string{"--pkg", "x", "a"}, "x", []string{"a"}, ""},
+		{withParams, []string{"a", "--pkg=x", "--", "--pkg", "y"}, "x", []string{"a", "--pkg", "y"}, ""},
+		{withParams, []string{"--pkg"}, "", nil, "missing value for parameter: --pkg"},
+		{noParams, []string{"--a", "--", "--b"}, "", []string{"--a", "--b"}, ""},
+	}
+
+	for i, test := range tests {
+		params, args, err := parseParams(c, test.target, test.args)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%d. unexpected error:\nexpected=%s,\nactual=%v\n", i, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d. unexpected error: %s\n", i, err.Error())
+			continue
+		}
+		if params["pkg"] != test.expPkg || strings.Join(args, " ") != strings.Join(test.expArgs, " ") {
+			t.Errorf("%d. unexpected result:\nexpected=%s %v,\nactual=%s %v\n", i, test.expPkg, test.expArgs, params["pkg"], args)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+//go:build windows
+
+package reporter
+
+// lockFile doesn't lock across processes where flock isn't available.
+func lockFile(path string) (func(), error) {
+	return func() {}, nil
+}
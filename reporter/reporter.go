@@ -0,0 +1,167 @@
+package reporter
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	Running = "running"
+	Passed  = "passed"
+	Failed  = "failed"
+
+	noTarget = "-"
+)
+
+// R publishes the state of a target outside of bacon's own output, such as to
+// a terminal title or a status bar.
+type R interface {
+	Report(target string, state string) error
+}
+
+type title struct {
+	appName string
+	out     io.Writer
+}
+
+// NewTitle creates a reporter that sets the terminal window title using an
+// OSC escape sequence.
+func NewTitle(appName string, out io.Writer) R {
+	return &title{
+		appName: appName,
+		out:     out,
+	}
+}
+
+func (t *title) Report(target string, state string) error {
+	text := t.appName
+	if target != "" {
+		text = fmt.Sprintf("%s: %s", text, target)
+	}
+	_, err := fmt.Fprintf(t.out, "\033]2;%s %s\007", text, state)
+	return err
+}
+
+type tmux struct {
+	option string
+}
+
+var tmuxUnsafe = regexp.MustCompile("[^A-Za-z0-9_]")
+
+// NewTmux creates a reporter that sets the given tmux user option, such as
+// "@bacon_status", to the target state. The state is also stored in a
+// per-target option of the same name suffixed with "_<target>".
+func NewTmux(option string) R {
+	return &tmux{
+		option: option,
+	}
+}
+
+func (t *tmux) Report(target string, state string) error {
+	if os.Getenv("TMUX") == "" {
+		return nil
+	}
+
+	err := exec.Command("tmux", "set", "-g", t.option, state).Run()
+	if err == nil && target != "" {
+		opt := t.option + "_" + tmuxUnsafe.ReplaceAllString(target, "_")
+		err = exec.Command("tmux", "set", "-g", opt, state).Run()
+	}
+	if err != nil {
+		return fmt.Errorf("tmux: %s", err.Error())
+	}
+	return nil
+}
+
+type file struct {
+	path string
+	mu   *sync.Mutex
+}
+
+// NewFile creates a reporter that maintains a status file with one
+// "<target> <state>" line per target. Updates take a lock on a "<path>.lock"
+// file, except on Windows, so that several bacon processes can share the
+// status file.
+func NewFile(path string) R {
+	return &file{
+		path: path,
+		mu:   &sync.Mutex{},
+	}
+}
+
+func (f *file) Report(target string, state string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	unlock, err := lockFile(f.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if target == "" {
+		target = noTarget
+	}
+
+	states, err := readStates(f.path)
+	if err != nil {
+		return err
+	}
+	states[target] = state
+
+	var names []string
+	for name := range states {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name + " " + states[name] + "\n")
+	}
+
+	// Write to a temporary file and rename it so that readers never see
+	// a partially written file.
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), ".bacon-status-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.WriteString(b.String()); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
+
+func readStates(path string) (map[string]string, error) {
+	states := make(map[string]string)
+
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return states, nil
+		}
+		return nil, err
+	}
+
+	for _, line := range strings.Split(string(bytes), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		states[fields[0]] = fields[1]
+	}
+	return states, nil
+}
@@ -0,0 +1,88 @@
+package reporter
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestTitle_Report(t *testing.T) {
+	var tests = []struct {
+		target string
+		state  string
+		exp    string
+	}{
+		{"", Running, "\033]2;bacon running\007"},
+		{"test", Passed, "\033]2;bacon: test passed\007"},
+	}
+
+	for i, test := range tests {
+		var out bytes.Buffer
+		err := NewTitle("bacon", &out).Report(test.target, test.state)
+		if err != nil {
+			t.Errorf("%d. unexpected error: %s\n", i, err.Error())
+		} else if out.String() != test.exp {
+			t.Errorf("%d. unexpected result:\nexpected=%q,\nactual=%q\n", i, test.exp, out.String())
+		}
+	}
+}
+
+func TestFile_Report(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status")
+	r := NewFile(path)
+
+	for _, report := range [][2]string{
+		{"test", Running},
+		{"", Failed},
+		{"lint", Passed},
+		{"test", Passed},
+	} {
+		if err := r.Report(report[0], report[1]); err != nil {
+			t.Fatalf("unexpected error: %s", err.Error())
+		}
+	}
+
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	exp := "- failed\nlint passed\ntest passed\n"
+	if string(bytes) != exp {
+		t.Errorf("unexpected result:\nexpected=%q,\nactual=%q\n", exp, string(bytes))
+	}
+}
+
+func TestFile_Report_Shared(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "status")
+
+	// Each reporter stands in for a separate process, having its own
+	// mutex, so that only the file lock orders their updates
+	var wg sync.WaitGroup
+	var exp strings.Builder
+	for i := 0; i < 20; i++ {
+		target := fmt.Sprintf("t%02d", i)
+		exp.WriteString(target + " passed\n")
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := NewFile(path).Report(target, Passed); err != nil {
+				t.Errorf("unexpected error: %s", err.Error())
+			}
+		}()
+	}
+	wg.Wait()
+
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if string(bytes) != exp.String() {
+		t.Errorf("unexpected result:\nexpected=%q,\nactual=%q\n", exp.String(), string(bytes))
+	}
+}
@@ -2,7 +2,10 @@ package util
 
 import (
 	"fmt"
+	"golang.org/x/term"
 	"os"
+	"regexp"
+	"strings"
 )
 
 func Exists(path string) (bool, error) {
@@ -34,3 +37,20 @@ func IsDir(path string) (bool, error) {
 func Cls() {
 	fmt.Print("\033c")
 }
+
+// IsTerminal answers whether the file is a terminal, rather than a pipe, a
+// regular file, or another character device such as /dev/null.
+func IsTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// ShellQuote quotes the string as a single word for a POSIX shell, leaving
+// it as it is when no quoting is necessary.
+func ShellQuote(s string) string {
+	if shellSafe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.Replace(s, "'", `'"'"'`, -1) + "'"
+}
@@ -0,0 +1,39 @@
+package util
+
+import (
+	"os"
+	"testing"
+)
+
+func TestShellQuote(t *testing.T) {
+	var tests = []struct {
+		s   string
+		exp string
+	}{
+		{"./...", "./..."},
+		{"example.com/m/a", "example.com/m/a"},
+		{"", "''"},
+		{"a b", "'a b'"},
+		{"$HOME", "'$HOME'"},
+		{"it's", `'it'"'"'s'`},
+	}
+
+	for i, test := range tests {
+		r := ShellQuote(test.s)
+		if r != test.exp {
+			t.Errorf("%d. unexpected result:\nexpected=%s,\nactual=%s\n", i, test.exp, r)
+		}
+	}
+}
+
+func TestIsTerminal(t *testing.T) {
+	f, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	defer f.Close()
+
+	if IsTerminal(f) {
+		t.Errorf("unexpected result:\nexpected=false,\nactual=true\n")
+	}
+}
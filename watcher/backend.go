@@ -0,0 +1,77 @@
+package watcher
+
+import (
+	"gopkg.in/fsnotify.v1"
+	"os"
+	"time"
+)
+
+// backend is a source of file change events for the directories that it
+// is asked to watch.
+type backend interface {
+	Add(dir string) error
+	Remove(dir string) error
+	Events() <-chan string
+	Errors() <-chan error
+	Close() error
+}
+
+// fileState is what's compared to decide whether a file has changed.
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+func stateOf(fi os.FileInfo) fileState {
+	return fileState{
+		modTime: fi.ModTime(),
+		size:    fi.Size(),
+	}
+}
+
+type fsnotifyBackend struct {
+	fsWatcher *fsnotify.Watcher
+	events    chan string
+}
+
+func newFsnotifyBackend() (backend, error) {
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	b := &fsnotifyBackend{
+		fsWatcher: fsWatcher,
+		events:    make(chan string),
+	}
+	go b.forward()
+
+	return b, nil
+}
+
+func (b *fsnotifyBackend) forward() {
+	for event := range b.fsWatcher.Events {
+		b.events <- event.Name
+	}
+	close(b.events)
+}
+
+func (b *fsnotifyBackend) Add(dir string) error {
+	return b.fsWatcher.Add(dir)
+}
+
+func (b *fsnotifyBackend) Remove(dir string) error {
+	return b.fsWatcher.Remove(dir)
+}
+
+func (b *fsnotifyBackend) Events() <-chan string {
+	return b.events
+}
+
+func (b *fsnotifyBackend) Errors() <-chan error {
+	return b.fsWatcher.Errors
+}
+
+func (b *fsnotifyBackend) Close() error {
+	return b.fsWatcher.Close()
+}
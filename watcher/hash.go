@@ -0,0 +1,111 @@
+package watcher
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"io"
+	"os"
+	"sync"
+)
+
+const (
+	// DefaultMaxHashes bounds the number of content hashes kept in memory.
+	// When exceeded, the least recently used hash is evicted, and the next
+	// change to that file is accepted on its modification time alone.
+	DefaultMaxHashes = 20000
+
+	// DefaultMaxHashSize is the size above which files are not hashed, and
+	// are compared by modification time and size only.
+	DefaultMaxHashSize = 32 << 20
+)
+
+type hashEntry struct {
+	path string
+	sum  [sha256.Size]byte
+}
+
+// hashCache is a bounded, least recently used cache of file content hashes.
+type hashCache struct {
+	maxEntries int
+	maxSize    int64
+
+	mu      *sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+func newHashCache(maxEntries int, maxSize int64) *hashCache {
+	return &hashCache{
+		maxEntries: maxEntries,
+		maxSize:    maxSize,
+
+		mu:      &sync.Mutex{},
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Changed hashes the file, and answers whether its content differs from the
+// last time it was hashed. A file that wasn't hashed before, is too large to
+// hash, or can't be read is considered changed.
+func (c *hashCache) Changed(path string, size int64) bool {
+	if size > c.maxSize {
+		c.Forget(path)
+		return true
+	}
+
+	sum, err := hashFile(path)
+	if err != nil {
+		c.Forget(path)
+		return true
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[path]; ok {
+		e := el.Value.(*hashEntry)
+		c.order.MoveToFront(el)
+		changed := e.sum != sum
+		e.sum = sum
+		return changed
+	}
+
+	c.entries[path] = c.order.PushFront(&hashEntry{path: path, sum: sum})
+	for c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*hashEntry).path)
+	}
+
+	return true
+}
+
+func (c *hashCache) Forget(path string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[path]; ok {
+		c.order.Remove(el)
+		delete(c.entries, path)
+	}
+}
+
+func hashFile(path string) ([sha256.Size]byte, error) {
+	var sum [sha256.Size]byte
+
+	f, err := os.Open(path)
+	if err != nil {
+		return sum, err
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return sum, err
+	}
+	copy(sum[:], h.Sum(nil))
+	return sum, nil
+}
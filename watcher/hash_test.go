@@ -0,0 +1,44 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHashCache_Changed(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a")
+	b := filepath.Join(dir, "b")
+
+	write := func(path string, content string) int64 {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return int64(len(content))
+	}
+
+	c := newHashCache(1, 8)
+
+	var tests = []struct {
+		path    string
+		content string
+		exp     bool
+	}{
+		{a, "one", true},       // first seen
+		{a, "one", false},      // same content
+		{a, "two", true},       // different content
+		{b, "one", true},       // first seen, evicts a
+		{a, "two", true},       // evicted, so considered changed
+		{a, "too large", true}, // exceeds the size limit
+		{a, "too large", true}, // still exceeds the size limit
+	}
+
+	for i, test := range tests {
+		size := write(test.path, test.content)
+		r := c.Changed(test.path, size)
+		if r != test.exp {
+			t.Errorf("%d. unexpected result:\nexpected=%t,\nactual=%t\n", i, test.exp, r)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+)
+
+const maxUserWatchesPath = "/proc/sys/fs/inotify/max_user_watches"
+
+// watchLimitError explains inotify's ENOSPC error, which means that the
+// per-user limit on watches has been reached rather than that a disk is full.
+func watchLimitError(err error, watches int) error {
+	if !errors.Is(err, syscall.ENOSPC) {
+		return err
+	}
+
+	limit := "the limit"
+	if b, rerr := os.ReadFile(maxUserWatchesPath); rerr == nil {
+		limit = "the limit of " + strings.TrimSpace(string(b))
+	}
+
+	return fmt.Errorf(
+		"inotify watch limit reached after watching %d directories: %s watches "+
+			"(fs.inotify.max_user_watches) is shared by all processes of this user. "+
+			"Exclude more directories with -e, or raise the limit with: "+
+			"sudo sysctl fs.inotify.max_user_watches=524288",
+		watches, limit)
+}
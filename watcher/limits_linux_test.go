@@ -0,0 +1,21 @@
+package watcher
+
+import (
+	"errors"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestWatchLimitError(t *testing.T) {
+	err := watchLimitError(syscall.ENOSPC, 42)
+	if !strings.Contains(err.Error(), "after watching 42 directories") ||
+		!strings.Contains(err.Error(), "fs.inotify.max_user_watches") {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+
+	other := errors.New("other")
+	if err := watchLimitError(other, 42); err != other {
+		t.Errorf("unexpected error: %s", err.Error())
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !linux
+
+package watcher
+
+func watchLimitError(err error, watches int) error {
+	return err
+}
@@ -0,0 +1,131 @@
+package watcher
+
+import (
+	"github.com/troykinsella/bacon/expander"
+	"os"
+	"time"
+)
+
+const DefaultPollInterval = time.Second
+
+// poller is a backend for file systems that don't support change
+// notifications, such as network mounts. On every interval, it lists the
+// expander's selected files, and emits an event for each file whose
+// modification time or size has changed, or that has appeared.
+type poller struct {
+	exp      *expander.E
+	interval time.Duration
+	states   map[string]fileState
+
+	events chan string
+	errors chan error
+	done   chan bool
+}
+
+func newPoller(exp *expander.E, interval time.Duration) (backend, error) {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+
+	p := &poller{
+		exp:      exp,
+		interval: interval,
+		states:   make(map[string]fileState),
+
+		events: make(chan string),
+		errors: make(chan error),
+		done:   make(chan bool),
+	}
+
+	// Take a snapshot so that the first poll only reports changes
+	if _, err := p.poll(); err != nil {
+		return nil, err
+	}
+
+	go p.run()
+
+	return p, nil
+}
+
+func (p *poller) run() {
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-time.After(p.interval):
+		}
+
+		changed, err := p.poll()
+		if err != nil {
+			select {
+			case p.errors <- err:
+			case <-p.done:
+			}
+			return
+		}
+
+		for _, path := range changed {
+			select {
+			case p.events <- path:
+			case <-p.done:
+				return
+			}
+		}
+	}
+}
+
+func (p *poller) poll() ([]string, error) {
+	files, err := p.exp.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	seen := make(map[string]bool, len(files))
+
+	for _, f := range files {
+		seen[f] = true
+
+		fi, err := os.Stat(f)
+		if err != nil {
+			continue // deleted since listing
+		}
+
+		cur := stateOf(fi)
+		last, ok := p.states[f]
+		p.states[f] = cur
+		if !ok || last != cur {
+			changed = append(changed, f)
+		}
+	}
+
+	for f := range p.states {
+		if !seen[f] {
+			delete(p.states, f)
+		}
+	}
+
+	return changed, nil
+}
+
+// Add does nothing, since the poller lists files using the expander.
+func (p *poller) Add(dir string) error {
+	return nil
+}
+
+func (p *poller) Remove(dir string) error {
+	return nil
+}
+
+func (p *poller) Events() <-chan string {
+	return p.events
+}
+
+func (p *poller) Errors() <-chan error {
+	return p.errors
+}
+
+func (p *poller) Close() error {
+	close(p.done)
+	return nil
+}
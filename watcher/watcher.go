@@ -3,33 +3,83 @@ package watcher
 import (
 	"errors"
 	"github.com/troykinsella/bacon/expander"
-	"gopkg.in/fsnotify.v1"
 	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type W struct {
-	exp       *expander.E
-	changed   ChangedFunc
-	done      chan error
-	fsWatcher *fsnotify.Watcher
-	lastMods  map[string]time.Time
+	exp      *expander.E
+	changed  ChangedFunc
+	done     chan error
+	stop     chan struct{}
+	stopOnce *sync.Once
+	backend  backend
+	lastMods map[string]fileState
+	hashes   *hashCache
+	started  bool
+	polls    bool
+
+	// watches counts the directories that the backend watches, which the
+	// poller doesn't. It's read by other goroutines, such as to render the
+	// status.
+	watches atomic.Int64
+
+	// When following symlinks, directories are watched by their real paths,
+	// and events are translated back to the paths reached through symlinks.
+	mu          *sync.Mutex
+	watched     map[string]bool
+	dirAliases  map[string]string
+	fileAliases map[string]string
 }
 
 type ChangedFunc func(f string)
 
+// New creates a watcher that is notified of changes by the operating system.
 func New(exp *expander.E) (*W, error) {
-	fsWatcher, err := fsnotify.NewWatcher()
+	b, err := newFsnotifyBackend()
 	if err != nil {
 		return nil, err
 	}
+	return newW(exp, b), nil
+}
 
+// NewPolling creates a watcher that polls the expander's selected files for
+// changes on the given interval, for file systems on which change
+// notifications don't work. A zero interval means DefaultPollInterval.
+func NewPolling(exp *expander.E, interval time.Duration) (*W, error) {
+	b, err := newPoller(exp, interval)
+	if err != nil {
+		return nil, err
+	}
+	w := newW(exp, b)
+	w.polls = true
+	return w, nil
+}
+
+func newW(exp *expander.E, b backend) *W {
 	return &W{
-		exp:       exp,
-		done:      make(chan error),
-		fsWatcher: fsWatcher,
-		lastMods:  make(map[string]time.Time),
-	}, nil
+		exp:      exp,
+		done:     make(chan error),
+		stop:     make(chan struct{}),
+		stopOnce: &sync.Once{},
+		backend:  b,
+		lastMods: make(map[string]fileState),
+
+		mu:          &sync.Mutex{},
+		watched:     make(map[string]bool),
+		dirAliases:  make(map[string]string),
+		fileAliases: make(map[string]string),
+	}
+}
+
+// UseContentHashes makes the watcher ignore changes that leave a file's
+// content as it was, such as touching it, by keeping a hash of the content
+// of each watched file.
+func (w *W) UseContentHashes() {
+	w.hashes = newHashCache(DefaultMaxHashes, DefaultMaxHashSize)
 }
 
 func (w *W) acceptEvent(path string) (bool, error) {
@@ -44,41 +94,88 @@ func (w *W) acceptEvent(path string) (bool, error) {
 	stat, err := os.Stat(path)
 	if err != nil {
 		delete(w.lastMods, path)
+		if w.hashes != nil {
+			w.hashes.Forget(path)
+		}
 		return false, nil // ignore
 	}
 
 	lastMod, ok := w.lastMods[path]
-	curMod := stat.ModTime()
+	curMod := stateOf(stat)
 	w.lastMods[path] = curMod
 	if ok && lastMod == curMod {
 		return false, nil
 	}
 
+	if w.hashes != nil && !w.hashes.Changed(path, stat.Size()) {
+		return false, nil
+	}
+
 	return true, nil
 }
 
+// primeHashes hashes the content of every selected file, so that the first
+// change to each is compared with its content at startup.
+func (w *W) primeHashes() error {
+	files, err := w.exp.List()
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		stat, err := os.Stat(f)
+		if err != nil {
+			continue
+		}
+		w.hashes.Changed(f, stat.Size())
+	}
+	return nil
+}
+
 func (w *W) changeWatcher() {
 	for {
 		select {
-		case event := <-w.fsWatcher.Events:
-			ok, err := w.acceptEvent(event.Name)
+		case path, open := <-w.backend.Events():
+			if !open {
+				return
+			}
+			path = w.logicalPath(path)
+			ok, err := w.acceptEvent(path)
 			if err != nil {
-				w.done <- err
+				w.fail(err)
 				break
 			}
 			if !ok {
 				continue
 			}
 
-			go w.changed(event.Name)
+			go w.changed(path)
 
-		case err := <-w.fsWatcher.Errors:
-			w.done <- err
+		case err := <-w.backend.Errors():
+			w.fail(err)
 			break
+
+		case <-w.stop:
+			return
 		}
 	}
 }
 
+// fail makes Run return the error, unless the watcher is stopped.
+func (w *W) fail(err error) {
+	select {
+	case w.done <- err:
+	case <-w.stop:
+	}
+}
+
+// Stop makes Run return nil, and stops watching.
+func (w *W) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+}
+
 func (w *W) watchPaths(paths []string) error {
 	for _, p := range paths {
 		err := w.watchPath(p)
@@ -90,22 +187,118 @@ func (w *W) watchPaths(paths []string) error {
 }
 
 func (w *W) watchPath(path string) error {
-	err := w.fsWatcher.Add(path)
-	return err
+	if w.exp.FollowsSymlinks() {
+		real, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			return err
+		}
+		if real != path {
+			w.mu.Lock()
+			if _, ok := w.dirAliases[real]; !ok {
+				w.dirAliases[real] = path
+			}
+			w.mu.Unlock()
+		}
+		path = real
+	}
+
+	if w.watched[path] {
+		return nil
+	}
+
+	err := w.backend.Add(path)
+	if err != nil {
+		return watchLimitError(err, int(w.watches.Load()))
+	}
+	w.watched[path] = true
+	if !w.polls {
+		w.watches.Add(1)
+	}
+	return nil
+}
+
+// watchLinkedFiles watches the real directory of each selected file that is a
+// symlink, since changes to a symlink's target aren't reported as changes in
+// the directory containing the symlink.
+func (w *W) watchLinkedFiles() error {
+	files, err := w.exp.List()
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		fi, err := os.Lstat(f)
+		if err != nil || fi.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+
+		real, err := filepath.EvalSymlinks(f)
+		if err != nil {
+			continue
+		}
+		w.mu.Lock()
+		w.fileAliases[real] = f
+		w.mu.Unlock()
+
+		err = w.watchPath(filepath.Dir(real))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// logicalPath translates a real path reported by the backend into the path
+// that was configured, through any symlinks.
+func (w *W) logicalPath(path string) string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if f, ok := w.fileAliases[path]; ok {
+		return f
+	}
+	if d, ok := w.dirAliases[filepath.Dir(path)]; ok {
+		return filepath.Join(d, filepath.Base(path))
+	}
+	return path
 }
 
 func (w *W) unwatchPath(path string) error {
-	err := w.fsWatcher.Remove(path)
+	err := w.backend.Remove(path)
+	if err == nil {
+		delete(w.watched, path)
+		if !w.polls {
+			w.watches.Add(-1)
+		}
+	}
 	return err
 }
 
-func (w *W) Run(changed ChangedFunc) error {
+// Watches returns the number of directories being watched, which is zero
+// when polling.
+func (w *W) Watches() int {
+	return int(w.watches.Load())
+}
+
+// Start watches the base directories of the expander's selected files, and
+// begins reporting changes to the function, without calling it for the
+// initial state. Starting a watcher that is already started does nothing.
+// When Start fails, the watcher can't be used.
+func (w *W) Start(changed ChangedFunc) error {
+	if w.started {
+		return nil
+	}
 	w.changed = changed
-	defer func(fsWatcher *fsnotify.Watcher) {
-		_ = fsWatcher.Close()
-	}(w.fsWatcher)
+	if err := w.start(); err != nil {
+		_ = w.backend.Close()
+		return err
+	}
+	w.started = true
 	go w.changeWatcher()
+	return nil
+}
 
+func (w *W) start() error {
 	dirs, err := w.exp.BaseDirs()
 	if err != nil {
 		return err
@@ -118,7 +311,41 @@ func (w *W) Run(changed ChangedFunc) error {
 	if err != nil {
 		return err
 	}
-	w.changed("") // don't wait for a change
 
-	return <-w.done
+	if w.exp.FollowsSymlinks() {
+		err = w.watchLinkedFiles()
+		if err != nil {
+			return err
+		}
+	}
+
+	if w.hashes != nil {
+		err = w.primeHashes()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run starts the watcher, unless it's already started, calls the function
+// right away, and then for each change, until the watcher fails or is
+// stopped. A watcher that is already started keeps reporting changes to the
+// function it was started with.
+func (w *W) Run(changed ChangedFunc) error {
+	if err := w.Start(changed); err != nil {
+		return err
+	}
+	defer func(b backend) {
+		_ = b.Close()
+	}(w.backend)
+
+	changed("") // don't wait for a change
+
+	select {
+	case err := <-w.done:
+		return err
+	case <-w.stop:
+		return nil
+	}
 }
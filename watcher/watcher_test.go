@@ -2,18 +2,206 @@ package watcher
 
 import (
 	"github.com/troykinsella/bacon/expander"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"testing"
 	"time"
 )
 
 func TestW_Run(t *testing.T) {
+	exp := expander.New("", []string{"testdata/foo", "testdata/bar"}, []string{})
+	w, err := New(exp)
+	if err != nil {
+		t.Errorf("Unexpected error: %s", err.Error())
+		return
+	}
+
+	testRun(t, w)
+}
+
+func TestW_Run_Polling(t *testing.T) {
+	exp := expander.New("", []string{"testdata/foo", "testdata/bar"}, []string{})
+	w, err := NewPolling(exp, 50*time.Millisecond)
+	if err != nil {
+		t.Errorf("Unexpected error: %s", err.Error())
+		return
+	}
+
+	testRun(t, w)
+}
+
+func testRun(t *testing.T, w *W) {
+	var err error
+
+	changes := make(chan string)
+
+	go w.Run(func(f string) {
+		changes <- filepath.Base(f)
+	})
+
+	// Ensure called right away
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Error("Initial callback timed out")
+		return
+	}
+
+	// Touch each watched file in turn, ensuring each is called back once, as
+	// a second call for foo would arrive ahead of the one for bar
+	for _, name := range []string{"foo", "bar"} {
+		err = touch(filepath.Join("testdata", name))
+		if err != nil {
+			t.Errorf("File change error: %s", err.Error())
+			return
+		}
+
+		select {
+		case f := <-changes:
+			if f != name {
+				t.Errorf("Unexpected callback: expected=%s, actual=%s", name, f)
+				return
+			}
+		case <-time.After(5 * time.Second):
+			t.Error("Watch callback timed out")
+			return
+		}
+	}
+}
+
+// touch rewrites the file's content in place with a single write, which is
+// reported as a single change, unlike truncating it first.
+func touch(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.WriteString("1\n")
+	return err
+}
+
+func TestW_Stop(t *testing.T) {
+	exp := expander.New("", []string{"testdata/foo"}, []string{})
+	w, err := New(exp)
+	if err != nil {
+		t.Errorf("Unexpected error: %s", err.Error())
+		return
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- w.Run(func(f string) {
+			w.Stop()
+		})
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Unexpected error: %s", err.Error())
+		}
+	case <-time.After(1 * time.Second):
+		t.Error("Stop timed out")
+	}
+}
+
+func TestW_Start(t *testing.T) {
+	exp := expander.New("", []string{"testdata/nothing/*"}, []string{})
+	w, err := New(exp)
+	if err != nil {
+		t.Errorf("Unexpected error: %s", err.Error())
+		return
+	}
+	err = w.Start(func(f string) {})
+	if err == nil || err.Error() != "no paths to watch were matched" {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	exp = expander.New("", []string{"testdata/foo"}, []string{})
+	w, err = New(exp)
+	if err != nil {
+		t.Errorf("Unexpected error: %s", err.Error())
+		return
+	}
+	stop := func(f string) {
+		w.Stop()
+	}
+	if err := w.Start(stop); err != nil {
+		t.Errorf("Unexpected error: %s", err.Error())
+		return
+	}
+	watches := w.Watches()
+	if err := w.Start(stop); err != nil {
+		t.Errorf("Unexpected error: %s", err.Error())
+	}
+	if w.Watches() != watches {
+		t.Errorf("Unexpected watches after second start: %d, %d", watches, w.Watches())
+	}
+
+	// Run uses the started watcher, calling right away
+	done := make(chan error)
+	go func() {
+		done <- w.Run(stop)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Unexpected error: %s", err.Error())
+		}
+	case <-time.After(1 * time.Second):
+		t.Error("Run timed out")
+	}
+}
+
+func TestW_Watches(t *testing.T) {
 	exp := expander.New("", []string{"testdata/foo"}, []string{})
+	notified, err := New(exp)
+	if err != nil {
+		t.Errorf("Unexpected error: %s", err.Error())
+		return
+	}
+	polling, err := NewPolling(exp, 50*time.Millisecond)
+	if err != nil {
+		t.Errorf("Unexpected error: %s", err.Error())
+		return
+	}
+
+	var tests = []struct {
+		w   *W
+		exp int
+	}{
+		{notified, 1},
+		{polling, 0},
+	}
+
+	for i, test := range tests {
+		if err := test.w.Start(func(f string) {}); err != nil {
+			t.Errorf("%d. unexpected error: %s\n", i, err.Error())
+			continue
+		}
+		if test.w.Watches() != test.exp {
+			t.Errorf("%d. unexpected result:\nexpected=%d,\nactual=%d\n", i, test.exp, test.w.Watches())
+		}
+		test.w.Stop()
+	}
+}
+
+func TestW_Run_ContentHashes(t *testing.T) {
+	dir := t.TempDir()
+	foo := filepath.Join(dir, "foo")
+	if err := os.WriteFile(foo, []byte("1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := expander.New(dir, []string{"foo"}, []string{})
 	w, err := New(exp)
 	if err != nil {
 		t.Errorf("Unexpected error: %s", err.Error())
 		return
 	}
+	w.UseContentHashes()
 
 	done := make(chan bool)
 
@@ -21,7 +209,6 @@ func TestW_Run(t *testing.T) {
 		done <- true
 	})
 
-	// Ensure called right away
 	select {
 	case <-done:
 	case <-time.After(1 * time.Second):
@@ -29,21 +216,79 @@ func TestW_Run(t *testing.T) {
 		return
 	}
 
-	// Touch watched file
-	err = exec.Command("sh", "-c", "echo 1 > testdata/foo").Run()
+	// Rewrite the same content
+	err = exec.Command("sh", "-c", "echo 1 > "+foo).Run()
 	if err != nil {
 		t.Errorf("File change error: %s", err.Error())
 		return
 	}
 
-	// Ensure called in reaction to touched file
 	select {
 	case <-done:
-		// Ensure not called again
-		select {
-		case <-done:
-			t.Error("Called back twice")
-		case <-time.After(1 * time.Second):
+		t.Error("Called back for unchanged content")
+		return
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	// Change the content
+	err = exec.Command("sh", "-c", "echo 2 > "+foo).Run()
+	if err != nil {
+		t.Errorf("File change error: %s", err.Error())
+		return
+	}
+
+	select {
+	case <-done:
+	case <-time.After(1 * time.Second):
+		t.Error("Watch callback timed out")
+	}
+}
+
+func TestW_Run_FollowSymlinks(t *testing.T) {
+	root := t.TempDir()
+	shared := t.TempDir()
+	target := filepath.Join(shared, "foo")
+	link := filepath.Join(root, "foo")
+	if err := os.WriteFile(target, []byte("1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := expander.New(root, []string{"foo"}, []string{})
+	exp.FollowSymlinks()
+	w, err := New(exp)
+	if err != nil {
+		t.Errorf("Unexpected error: %s", err.Error())
+		return
+	}
+
+	changed := make(chan string)
+
+	go w.Run(func(f string) {
+		changed <- f
+	})
+
+	select {
+	case <-changed:
+	case <-time.After(1 * time.Second):
+		t.Error("Initial callback timed out")
+		return
+	}
+
+	// Change the symlink's target
+	err = exec.Command("sh", "-c", "echo 2 > "+target).Run()
+	if err != nil {
+		t.Errorf("File change error: %s", err.Error())
+		return
+	}
+
+	// Ensure the change is reported with the symlink's path
+	select {
+	case f := <-changed:
+		if f != link {
+			t.Errorf("unexpected changed path:\nexpected=%s,\nactual=%s\n", link, f)
 		}
 	case <-time.After(1 * time.Second):
 		t.Error("Watch callback timed out")